@@ -0,0 +1,297 @@
+// Command pan-gen generates code that lets pan read the columns of SQLTableNamers, and
+// read rows into them, without using reflection.
+//
+// Usage:
+//
+//	pan-gen [-type T,U] [-output file] [dir]
+//
+// pan-gen reads the Go package in `dir`, which defaults to the current directory. For
+// each of the struct types named using -type, or every struct type with a
+// GetSQLTableName method if -type isn’t set, it writes:
+//
+//   - a string type for the names of its columns, like PersonColumn, and a constant of
+//     that type holding the name of each column, like PersonColumnFName
+//   - SQLColumns and SQLColumnValues methods, filling pan’s ColumnLister interface
+//   - an SQLScanTarget method on a pointer to the type, filling pan’s ScanTargeter
+//     interface
+//
+// Columns are named using the same rules pan uses at runtime, and two properties of a type
+// can’t map to the same column. The code is written to pan_gen.go in `dir`, unless -output
+// is set. pan-gen is meant to be run by go generate:
+//
+//	//go:generate pan-gen -type Person
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"darlinggo.co/pan/internal/fields"
+)
+
+const defaultOutput = "pan_gen.go"
+
+// table is a struct type code is generated for.
+type table struct {
+	name    string
+	columns []column
+}
+
+// column is a property of a table and the column it maps to.
+type column struct {
+	property string
+	name     string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pan-gen: ")
+	typeNames := flag.String("type", "", "comma-separated list of type names; defaults to every struct type with a GetSQLTableName method")
+	output := flag.String("output", "", "output file name; defaults to "+defaultOutput+" in the package directory")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: pan-gen [-type T,U] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, defaultOutput)
+	}
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+	pkg, tables, err := parsePackage(dir, filepath.Base(*output), types)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(pkg, tables)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parsePackage reads the package in `dir`, skipping the file named `skip`, and returns
+// its name and the tables for `types`. If `types` is empty, the tables for every struct
+// type with a GetSQLTableName method are returned.
+func parsePackage(dir, skip string, types []string) (string, []table, error) {
+	info, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	var specs []*ast.TypeSpec
+	namers := map[string]bool{}
+	for _, name := range info.GoFiles {
+		if name == skip {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if spec, ok := spec.(*ast.TypeSpec); ok {
+						specs = append(specs, spec)
+					}
+				}
+			case *ast.FuncDecl:
+				if decl.Recv != nil && decl.Name.Name == "GetSQLTableName" && len(decl.Recv.List) == 1 {
+					if ident, ok := unstar(decl.Recv.List[0].Type).(*ast.Ident); ok {
+						namers[ident.Name] = true
+					}
+				}
+			}
+		}
+	}
+	var tables []table
+	if len(types) < 1 {
+		for _, spec := range specs {
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok || spec.TypeParams != nil || !namers[spec.Name.Name] {
+				continue
+			}
+			cols, err := columns(spec.Name.Name, st)
+			if err != nil {
+				return "", nil, err
+			}
+			tables = append(tables, table{name: spec.Name.Name, columns: cols})
+		}
+		return info.Name, tables, nil
+	}
+	for _, name := range types {
+		var found bool
+		for _, spec := range specs {
+			if spec.Name.Name != name {
+				continue
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return "", nil, fmt.Errorf("type %s isn't a struct", name)
+			}
+			if spec.TypeParams != nil {
+				return "", nil, fmt.Errorf("type %s is generic, which isn't supported", name)
+			}
+			cols, err := columns(name, st)
+			if err != nil {
+				return "", nil, err
+			}
+			tables = append(tables, table{name: name, columns: cols})
+			found = true
+			break
+		}
+		if !found {
+			return "", nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+	}
+	return info.Name, tables, nil
+}
+
+// columns returns the columns for the properties of `st`, the struct type `name`, in the
+// order they’re declared. If two properties map to the same column, an error naming both
+// is returned, as the generated SQLScanTarget couldn’t tell them apart.
+func columns(name string, st *ast.StructType) ([]column, error) {
+	var results []column
+	seen := map[string]string{}
+	for _, field := range st.Fields.List {
+		var tag string
+		if field.Tag != nil {
+			tag, _ = strconv.Unquote(field.Tag.Value)
+		}
+		names := field.Names
+		if len(names) < 1 {
+			// embedded fields are named after their type
+			names = []*ast.Ident{ast.NewIdent(embeddedName(field.Type))}
+		}
+		for _, ident := range names {
+			if !ast.IsExported(ident.Name) {
+				continue
+			}
+			col := fields.Column(reflect.StructField{Name: ident.Name, Tag: reflect.StructTag(tag)})
+			if col == "" {
+				continue
+			}
+			if prev, ok := seen[col]; ok {
+				return nil, fmt.Errorf("properties %s and %s of type %s both map to column %q", prev, ident.Name, name, col)
+			}
+			seen[col] = ident.Name
+			results = append(results, column{property: ident.Name, name: col})
+		}
+	}
+	return results, nil
+}
+
+// unstar returns the type `expr` points to, if it’s a pointer type.
+func unstar(expr ast.Expr) ast.Expr {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		return star.X
+	}
+	return expr
+}
+
+// embeddedName returns the name of a field embedding the type `expr`.
+func embeddedName(expr ast.Expr) string {
+	switch expr := unstar(expr).(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	case *ast.IndexExpr:
+		return embeddedName(expr.X)
+	case *ast.IndexListExpr:
+		return embeddedName(expr.X)
+	}
+	return ""
+}
+
+// generate returns the formatted source of a file in package `pkg` holding the
+// generated code for `tables`.
+func generate(pkg string, tables []table) ([]byte, error) {
+	if len(tables) < 1 {
+		return nil, errors.New("no types to generate code for")
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by pan-gen; DO NOT EDIT.\n\npackage %s\n", pkg)
+	for _, t := range tables {
+		recv := receiver(t.name)
+		constants := make([]string, 0, len(t.columns))
+		for _, col := range t.columns {
+			constants = append(constants, t.name+"Column"+col.property)
+		}
+
+		colType := t.name + "Column"
+		fmt.Fprintf(&buf, "\n// %s is the name of a column %s maps to.\ntype %s string\n", colType, t.name, colType)
+		fmt.Fprintf(&buf, "\n// The columns %s maps to.\nconst (\n", t.name)
+		for pos, col := range t.columns {
+			fmt.Fprintf(&buf, "\t%s %s = %q\n", constants[pos], colType, col.name)
+		}
+		fmt.Fprintf(&buf, ")\n")
+
+		names := make([]string, 0, len(constants))
+		for _, constant := range constants {
+			names = append(names, "string("+constant+")")
+		}
+		fmt.Fprintf(&buf, "\n// SQLColumns returns the names of the columns %s maps to.\n", t.name)
+		fmt.Fprintf(&buf, "func (%s %s) SQLColumns() []string {\n", recv, t.name)
+		fmt.Fprintf(&buf, "\treturn []string{%s}\n}\n", strings.Join(names, ", "))
+
+		values := make([]string, 0, len(t.columns))
+		for _, col := range t.columns {
+			values = append(values, recv+"."+col.property)
+		}
+		fmt.Fprintf(&buf, "\n// SQLColumnValues returns the values of the columns %s maps to.\n", t.name)
+		fmt.Fprintf(&buf, "func (%s %s) SQLColumnValues() []any {\n", recv, t.name)
+		fmt.Fprintf(&buf, "\treturn []any{%s}\n}\n", strings.Join(values, ", "))
+
+		fmt.Fprintf(&buf, "\n// SQLScanTarget returns a pointer to the property of %s that column is read into.\n", t.name)
+		fmt.Fprintf(&buf, "func (%s *%s) SQLScanTarget(column string) any {\n", recv, t.name)
+		if len(t.columns) > 0 {
+			fmt.Fprintf(&buf, "\tswitch %s(column) {\n", colType)
+			for pos, col := range t.columns {
+				fmt.Fprintf(&buf, "\tcase %s:\n\t\treturn &%s.%s\n", constants[pos], recv, col.property)
+			}
+			fmt.Fprintf(&buf, "\t}\n")
+		}
+		fmt.Fprintf(&buf, "\treturn nil\n}\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// receiver returns the receiver name for methods on the type `name`: its first
+// letter, lowercased.
+func receiver(name string) string {
+	r, _ := utf8.DecodeRuneInString(name)
+	recv := string(unicode.ToLower(r))
+	if recv == "_" {
+		recv = "t"
+	}
+	return recv
+}
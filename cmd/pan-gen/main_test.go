@@ -0,0 +1,174 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSource = `package people
+
+import "time"
+
+type Person struct {
+	ID       int    ` + "`sql_column:\"person_id,pk\"`" + `
+	FName    string ` + "`sql_column:\"fname\"`" + `
+	LName    string
+	Secret   string ` + "`sql_column:\"-\"`" + `
+	nickname string
+	time.Time
+	A, B     int
+}
+
+func (p *Person) GetSQLTableName() string {
+	return "people"
+}
+
+type notATable struct {
+	Value int
+}
+`
+
+const expected = `// Code generated by pan-gen; DO NOT EDIT.
+
+package people
+
+// PersonColumn is the name of a column Person maps to.
+type PersonColumn string
+
+// The columns Person maps to.
+const (
+	PersonColumnID    PersonColumn = "person_id"
+	PersonColumnFName PersonColumn = "fname"
+	PersonColumnLName PersonColumn = "lname"
+	PersonColumnTime  PersonColumn = "time"
+	PersonColumnA     PersonColumn = "a"
+	PersonColumnB     PersonColumn = "b"
+)
+
+// SQLColumns returns the names of the columns Person maps to.
+func (p Person) SQLColumns() []string {
+	return []string{string(PersonColumnID), string(PersonColumnFName), string(PersonColumnLName), string(PersonColumnTime), string(PersonColumnA), string(PersonColumnB)}
+}
+
+// SQLColumnValues returns the values of the columns Person maps to.
+func (p Person) SQLColumnValues() []any {
+	return []any{p.ID, p.FName, p.LName, p.Time, p.A, p.B}
+}
+
+// SQLScanTarget returns a pointer to the property of Person that column is read into.
+func (p *Person) SQLScanTarget(column string) any {
+	switch PersonColumn(column) {
+	case PersonColumnID:
+		return &p.ID
+	case PersonColumnFName:
+		return &p.FName
+	case PersonColumnLName:
+		return &p.LName
+	case PersonColumnTime:
+		return &p.Time
+	case PersonColumnA:
+		return &p.A
+	case PersonColumnB:
+		return &p.B
+	}
+	return nil
+}
+`
+
+func writePackage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "people.go"), []byte(testSource), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	// a stale output file shouldn't be read
+	if err := os.WriteFile(filepath.Join(dir, defaultOutput), []byte("package people\n\nfunc (p Person) SQLColumns() {}\n"), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	dir := writePackage(t)
+	pkg, tables, err := parsePackage(dir, defaultOutput, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if pkg != "people" {
+		t.Errorf("Expected package %s, got %s", "people", pkg)
+	}
+	src, err := generate(pkg, tables)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if string(src) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, src)
+	}
+}
+
+func TestParseTypes(t *testing.T) {
+	t.Parallel()
+	dir := writePackage(t)
+	_, tables, err := parsePackage(dir, defaultOutput, []string{"notATable"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	want := []table{{name: "notATable", columns: []column{{property: "Value", name: "value"}}}}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("Expected %+v, got %+v", want, tables)
+	}
+	if _, _, err := parsePackage(dir, defaultOutput, []string{"Missing"}); err == nil {
+		t.Errorf("Expected an error for a missing type, got nil")
+	}
+}
+
+func TestGeneratedCompiles(t *testing.T) {
+	t.Parallel()
+	dir := writePackage(t)
+	pkg, tables, err := parsePackage(dir, defaultOutput, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	src, err := generate(pkg, tables)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, source := range map[string]string{"people.go": testSource, defaultOutput: string(src)} {
+		file, err := parser.ParseFile(fset, name, source, 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+		files = append(files, file)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check(pkg, fset, files, nil); err != nil {
+		t.Errorf("Generated code doesn't compile: %+v", err)
+	}
+}
+
+func TestDuplicateColumns(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	src := "package rows\n\ntype Row struct {\n\tA int `sql_column:\"x\"`\n\tB int `sql_column:\"x\"`\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "rows.go"), []byte(src), 0o644); err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	_, _, err := parsePackage(dir, defaultOutput, []string{"Row"})
+	if err == nil {
+		t.Fatalf("Expected an error for duplicate columns, got nil")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "A and B") || !strings.Contains(msg, `"x"`) {
+		t.Errorf("Expected the error to name both properties and the column, got %q", msg)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const panPath = "darlinggo.co/pan"
+
+// Analyzer reports calls to pan that look up a property by name on an SQLTableNamer whose
+// struct type is known when the package is compiled, when the name isn’t an exported
+// property of the struct, or the property is omitted from the columns using the `-` tag.
+// The properties of SelectProperties are checked against the type argument of the Select
+// they’re passed to, those of a Conflict literal against the type of the Upsert it’s passed
+// to, and those of a Keyset literal against its Table. Names that aren’t constants, and
+// SQLTableNamers whose type is an interface, aren’t checked.
+var Analyzer = &analysis.Analyzer{
+	Name:     "pan",
+	Doc:      "check property names passed to pan match the columns of their struct",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// propertyArgs holds the positions of an SQLTableNamer and the name of one of its
+// properties in the arguments of a call.
+type propertyArgs struct {
+	obj, property int
+
+	// variadic is true if every argument after property is a property, too.
+	variadic bool
+}
+
+// checked maps the full names of the functions that are checked to the positions of
+// the properties they take.
+var checked = map[string][]propertyArgs{
+	panPath + ".Column":                          {{obj: 0, property: 1}},
+	panPath + ".Comparison":                      {{obj: 0, property: 1}},
+	panPath + ".In":                              {{obj: 0, property: 1}},
+	panPath + ".NotIn":                           {{obj: 0, property: 1}},
+	panPath + ".Between":                         {{obj: 0, property: 1}},
+	panPath + ".IsNull":                          {{obj: 0, property: 1}},
+	panPath + ".IsNotNull":                       {{obj: 0, property: 1}},
+	panPath + ".Like":                            {{obj: 0, property: 1}},
+	panPath + ".ILike":                           {{obj: 0, property: 1}},
+	panPath + ".Update":                          {{obj: 0, property: 1, variadic: true}},
+	"(*" + panPath + ".Query).Comparison":        {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).In":                {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).NotIn":             {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).Between":           {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).IsNull":            {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).IsNotNull":         {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).Like":              {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).ILike":             {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).Assign":            {{obj: 0, property: 1}},
+	"(*" + panPath + ".Query).Join":              {{obj: 1, property: 2}, {obj: 3, property: 4}},
+	"(*" + panPath + ".Query).Returning":         {{obj: 0, property: 1, variadic: true}},
+	"(*" + panPath + ".Query).GroupByProperties": {{obj: 0, property: 1, variadic: true}},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}, func(n ast.Node) {
+		if lit, ok := n.(*ast.CompositeLit); ok {
+			// Keyset literals hold the SQLTableNamer their properties belong to
+			if isPanType(pass, lit, "Keyset") {
+				if typ := tableType(pass, fieldValue(lit, "Table")); typ != nil {
+					checkList(pass, typ, fieldValue(lit, "Properties"))
+				}
+			}
+			return
+		}
+		call := n.(*ast.CallExpr)
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != panPath {
+			return
+		}
+		switch fn.FullName() {
+		case panPath + ".Select":
+			// the properties of SelectProperties belong to Select's type argument
+			typ := typeArg(pass, call)
+			if typ == nil {
+				return
+			}
+			for _, arg := range call.Args {
+				option, ok := astutil.Unparen(arg).(*ast.CallExpr)
+				if !ok {
+					continue
+				}
+				if fn, ok := typeutil.Callee(pass.TypesInfo, option).(*types.Func); ok && fn.FullName() == panPath+".SelectProperties" {
+					for _, property := range option.Args {
+						checkProperty(pass, typ, property)
+					}
+				}
+			}
+			return
+		case panPath + ".Upsert":
+			typ := typeArg(pass, call)
+			if typ == nil || len(call.Args) < 1 {
+				return
+			}
+			if lit, ok := astutil.Unparen(call.Args[0]).(*ast.CompositeLit); ok {
+				checkList(pass, typ, fieldValue(lit, "Target"))
+				checkList(pass, typ, fieldValue(lit, "Update"))
+			}
+			return
+		}
+		for _, args := range checked[fn.FullName()] {
+			if args.obj >= len(call.Args) {
+				continue
+			}
+			typ := tableType(pass, call.Args[args.obj])
+			if typ == nil {
+				continue
+			}
+			end := args.property + 1
+			if args.variadic {
+				end = len(call.Args)
+			}
+			for i := args.property; i < end && i < len(call.Args); i++ {
+				checkProperty(pass, typ, call.Args[i])
+			}
+		}
+	})
+	return nil, nil
+}
+
+// tableType returns the struct type of the SQLTableNamer `expr`, seeing through
+// pointers and calls to pan.Aliased, or nil if it isn’t known.
+func tableType(pass *analysis.Pass, expr ast.Expr) types.Type {
+	if expr == nil {
+		return nil
+	}
+	expr = astutil.Unparen(expr)
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) > 0 {
+		if fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func); ok && fn.FullName() == panPath+".Aliased" {
+			return tableType(pass, call.Args[0])
+		}
+	}
+	return structType(pass.TypesInfo.TypeOf(expr))
+}
+
+// structType returns `typ`, or the type it points to, if it’s a struct type, or nil
+// if it isn’t.
+func structType(typ types.Type) types.Type {
+	if typ == nil {
+		return nil
+	}
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if _, ok := typ.Underlying().(*types.Struct); !ok {
+		return nil
+	}
+	return typ
+}
+
+// typeArg returns the struct type of the first type argument of the generic function
+// `call` calls, whether it’s written out or inferred, or nil if it isn’t known.
+func typeArg(pass *analysis.Pass, call *ast.CallExpr) types.Type {
+	fun := astutil.Unparen(call.Fun)
+	switch index := fun.(type) {
+	case *ast.IndexExpr:
+		fun = index.X
+	case *ast.IndexListExpr:
+		fun = index.X
+	}
+	var ident *ast.Ident
+	switch fun := astutil.Unparen(fun).(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	}
+	inst, ok := pass.TypesInfo.Instances[ident]
+	if !ok || inst.TypeArgs.Len() < 1 {
+		return nil
+	}
+	return structType(inst.TypeArgs.At(0))
+}
+
+// isPanType returns whether `expr` is a value of the pan type `name`.
+func isPanType(pass *analysis.Pass, expr ast.Expr, name string) bool {
+	named, ok := pass.TypesInfo.TypeOf(expr).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == panPath && named.Obj().Name() == name
+}
+
+// fieldValue returns the value of the field `name` in the keyed composite literal
+// `lit`, or nil if it isn’t set.
+func fieldValue(lit *ast.CompositeLit, name string) ast.Expr {
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok && key.Name == name {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+// checkList checks each element of `expr` using checkProperty, if it’s a composite
+// literal, like []string{"ID", "Title"}.
+func checkList(pass *analysis.Pass, typ types.Type, expr ast.Expr) {
+	lit, ok := astutil.Unparen(expr).(*ast.CompositeLit)
+	if !ok {
+		return
+	}
+	for _, elt := range lit.Elts {
+		checkProperty(pass, typ, elt)
+	}
+}
+
+// checkProperty reports `expr` if it’s a constant that doesn’t name an exported,
+// non-omitted property of `typ`.
+func checkProperty(pass *analysis.Pass, typ types.Type, expr ast.Expr) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	name := constant.StringVal(tv.Value)
+	var pkg *types.Package
+	if named, ok := typ.(*types.Named); ok {
+		pkg = named.Obj().Pkg()
+	}
+	obj, index, _ := types.LookupFieldOrMethod(typ, false, pkg, name)
+	field, ok := obj.(*types.Var)
+	switch {
+	case !ok:
+		pass.Reportf(expr.Pos(), "%s has no property %q", typ, name)
+	case !field.Exported():
+		pass.Reportf(expr.Pos(), "property %q of %s is unexported, so it has no column", name, typ)
+	case isOmitted(typ, index):
+		pass.Reportf(expr.Pos(), "property %q of %s is omitted from its columns by its sql_column tag", name, typ)
+	}
+}
+
+// isOmitted returns whether the field at `index` in `typ` is omitted from the
+// columns using the `-` tag.
+func isOmitted(typ types.Type, index []int) bool {
+	var tag string
+	for _, i := range index {
+		if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return false
+		}
+		tag = st.Tag(i)
+		typ = st.Field(i).Type()
+	}
+	column, _, _ := strings.Cut(reflect.StructTag(tag).Get("sql_column"), ",")
+	return column == "-"
+}
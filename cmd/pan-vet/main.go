@@ -0,0 +1,18 @@
+// Command pan-vet reports property names passed to pan that don’t match a column of the
+// struct they’re looked up on, which would otherwise panic at runtime.
+//
+// It can be run on its own, or by go vet:
+//
+//	go install darlinggo.co/pan/cmd/pan-vet@latest
+//	go vet -vettool=$(which pan-vet) ./...
+//
+// See Analyzer for the checks it runs.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(Analyzer)
+}
@@ -0,0 +1,75 @@
+package a
+
+import "darlinggo.co/pan"
+
+type embedded struct {
+	Shared string
+}
+
+type Post struct {
+	embedded
+	ID      int `sql_column:"post_id"`
+	Title   string
+	Draft   bool `sql_column:"-"`
+	private string
+}
+
+func (p Post) GetSQLTableName() string { return "posts" }
+
+func (p Post) Helper() string { return "" }
+
+type Tag struct {
+	PostID int
+}
+
+func (t *Tag) GetSQLTableName() string { return "tags" }
+
+const title = "Titel"
+
+func queries(p Post, t *Tag, n pan.SQLTableNamer, name string) {
+	pan.Column(p, "ID")
+	pan.Column(p, "Shared")
+	pan.Column(p, "Titel")   // want `a.Post has no property "Titel"`
+	pan.Column(&p, title)    // want `a.Post has no property "Titel"`
+	pan.Column(p, "Draft")   // want `property "Draft" of a.Post is omitted from its columns by its sql_column tag`
+	pan.Column(p, "private") // want `property "private" of a.Post is unexported, so it has no column`
+	pan.Column(p, "Helper")  // want `a.Post has no property "Helper"`
+	pan.Column(p, name)
+	pan.Column(n, "Anything")
+	pan.Col(&p, &p.Title)
+	pan.IsNull(t, "PostID")
+	pan.Like(p, "Title", "x", 0)
+	pan.Comparison(pan.Aliased(t, "t"), "PostId", "=", 1) // want `a.Tag has no property "PostId"`
+	pan.In(p, "Tags", 1, 2)                               // want `a.Post has no property "Tags"`
+	pan.NotIn(p, "Tag", []int{1, 2})                      // want `a.Post has no property "Tag"`
+	pan.Between(p, "Created", 1, 2)                       // want `a.Post has no property "Created"`
+	pan.IsNotNull(t, "Post")                              // want `a.Tag has no property "Post"`
+	pan.ILike(p, "Draft", "x", 0)                         // want `property "Draft" of a.Post is omitted from its columns by its sql_column tag`
+	pan.Update(p, "Title", "Body")                        // want `a.Post has no property "Body"`
+
+	var q pan.Query
+	q.Comparison(p, "Title", "=", "x").
+		In(t, "Post", 1). // want `a.Tag has no property "Post"`
+		NotIn(t, "PostID").
+		Assign(p, "Titles", "x") // want `a.Post has no property "Titles"`
+	q.Join("INNER JOIN", p, "Id", t, "PostID") // want `a.Post has no property "Id"`
+	q.Join("INNER JOIN", p, "ID", t, "ID")     // want `a.Tag has no property "ID"`
+	q.Returning(p, "ID", "Created")            // want `a.Post has no property "Created"`
+	q.GroupByProperties(p, "Title", "Author")  // want `a.Post has no property "Author"`
+	q.Between(p, "ID", 1, 2).IsNotNull(t, "PostID").
+		IsNull(p, "Body").         // want `a.Post has no property "Body"`
+		Like(p, "Titles", "x", 0). // want `a.Post has no property "Titles"`
+		ILike(t, "Tag", "x", 0)    // want `a.Tag has no property "Tag"`
+
+	pan.Select[Post](pan.SelectAs("p"), pan.SelectProperties("ID", "Draft")) // want `property "Draft" of a.Post is omitted from its columns by its sql_column tag`
+	pan.Select[*Tag](pan.SelectProperties("PostID", "Tag"))                  // want `a.Tag has no property "Tag"`
+	pan.Select[Post]()
+
+	pan.Upsert(pan.Conflict{Target: []string{"Title"}, Update: []string{"Body"}}, p) // want `a.Post has no property "Body"`
+	pan.Upsert[*Tag](pan.Conflict{Target: []string{"ID"}, DoNothing: true}, t)       // want `a.Tag has no property "ID"`
+	pan.Upsert(pan.Conflict{Update: []string{name}}, p)
+
+	_ = pan.Keyset{Table: p, Properties: []string{"Title", "private"}}          // want `property "private" of a.Post is unexported, so it has no column`
+	_ = &pan.Keyset{Table: pan.Aliased(t, "t"), Properties: []string{"Weight"}} // want `a.Tag has no property "Weight"`
+	_ = pan.Keyset{Table: n, Properties: []string{"Anything"}}
+}
@@ -0,0 +1,89 @@
+// Package pan is a stub of darlinggo.co/pan, declaring the functions the
+// analyzer checks.
+package pan
+
+type SQLTableNamer interface {
+	GetSQLTableName() string
+}
+
+type Flag int
+
+type Query struct{}
+
+type JoinKind string
+
+type LikeMode int
+
+type SelectOption func()
+
+type Conflict struct {
+	Target    []string
+	Update    []string
+	DoNothing bool
+}
+
+type Keyset struct {
+	Table      SQLTableNamer
+	Properties []string
+	Descending bool
+}
+
+func Column(s SQLTableNamer, property string, flags ...Flag) string { return "" }
+
+func Col(s SQLTableNamer, field any, flags ...Flag) string { return "" }
+
+func Comparison(obj SQLTableNamer, property any, operator string, value any) any { return nil }
+
+func In(obj SQLTableNamer, property any, values ...any) any { return nil }
+
+func NotIn(obj SQLTableNamer, property any, values ...any) any { return nil }
+
+func Between(obj SQLTableNamer, property any, low, high any) any { return nil }
+
+func IsNull(obj SQLTableNamer, property any) any { return nil }
+
+func IsNotNull(obj SQLTableNamer, property any) any { return nil }
+
+func Like(obj SQLTableNamer, property any, value string, mode LikeMode) any { return nil }
+
+func ILike(obj SQLTableNamer, property any, value string, mode LikeMode) any { return nil }
+
+func Aliased(obj SQLTableNamer, alias string) SQLTableNamer { return obj }
+
+func Update[Type SQLTableNamer](value Type, properties ...string) *Query { return nil }
+
+func SelectProperties(properties ...string) SelectOption { return nil }
+
+func SelectAs(alias string) SelectOption { return nil }
+
+func Select[Type SQLTableNamer](options ...SelectOption) *Query { return nil }
+
+func Upsert[Type SQLTableNamer](conflict Conflict, values ...Type) *Query { return nil }
+
+func (q *Query) Comparison(obj SQLTableNamer, property any, operator string, value any) *Query {
+	return q
+}
+
+func (q *Query) In(obj SQLTableNamer, property any, values ...any) *Query { return q }
+
+func (q *Query) NotIn(obj SQLTableNamer, property any, values ...any) *Query { return q }
+
+func (q *Query) Between(obj SQLTableNamer, property any, low, high any) *Query { return q }
+
+func (q *Query) IsNull(obj SQLTableNamer, property any) *Query { return q }
+
+func (q *Query) IsNotNull(obj SQLTableNamer, property any) *Query { return q }
+
+func (q *Query) Like(obj SQLTableNamer, property any, value string, mode LikeMode) *Query { return q }
+
+func (q *Query) ILike(obj SQLTableNamer, property any, value string, mode LikeMode) *Query { return q }
+
+func (q *Query) Assign(obj SQLTableNamer, property any, value any) *Query { return q }
+
+func (q *Query) Join(kind JoinKind, left SQLTableNamer, leftProp any, right SQLTableNamer, rightProp any, flags ...Flag) *Query {
+	return q
+}
+
+func (q *Query) Returning(obj SQLTableNamer, properties ...any) *Query { return q }
+
+func (q *Query) GroupByProperties(obj SQLTableNamer, properties ...any) *Query { return q }
@@ -0,0 +1,300 @@
+package pan
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+)
+
+// The precedence of the boolean operators a Condition can be built with, from
+// loosest to tightest. Conditions are wrapped in parentheses when they're used
+// as an operand of an operator that binds tighter than they do.
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceNot
+	precedenceAtom
+)
+
+// Condition is a boolean SQL expression and the arguments for its placeholders, like
+// those passed to Query.Where. Conditions are built using Comparison, In, NotIn, Between,
+// IsNull, IsNotNull, Like, ILike, and Expression, and combined using And, Or, and Not,
+// which wrap their operands in parentheses only when SQL’s operator precedence requires
+// it.
+//
+// The empty Condition has no SQL, and is left out by And, Or, Not, and Query.Where.
+type Condition struct {
+	sql        string
+	args       []any
+	precedence int
+
+	// err holds the first error encountered while building the Condition, and
+	// is passed on to the Query the Condition is added to.
+	err error
+}
+
+// Expression returns a Condition made up of a raw string and optional values. `key` and
+// `values` follow the same rules as they do for Query.Expression, so `key` can use named
+// placeholders and `values` can include subqueries.
+func Expression(key string, values ...any) Condition {
+	key, values, err := compileExpression(key, values)
+	return Condition{sql: key, args: values, precedence: precedence(key), err: err}
+}
+
+// Comparison returns a Condition in the form of `column operator ?`, with `value` as its
+// argument. Column is determined by finding the column name for the passed property on the
+// passed SQLTableNamer. The passed property must be a string that matches, identically, the
+// property name, or a pointer to the field, like Col takes; if it does not, it will panic. If
+// `value` is a *Query, it is used as a subquery.
+func Comparison(obj SQLTableNamer, property any, operator string, value any) Condition {
+	return Expression(column(obj, property)+" "+operator+" ?", value)
+}
+
+// In returns a Condition in the form of "column IN (value, value, value)". `values` are the
+// variables to match against, and `obj` and `property` are used to determine the column.
+// `property` must exactly match the name of a property on `obj`, or be a pointer to the field,
+// like Col takes, or the call will panic. If the only value is a *Query, the Condition takes
+// the form "column IN (subquery)".
+//
+// Slices and arrays in `values` are expanded, so each of their elements is matched against,
+// except for []byte and values that implement driver.Valuer. If there are no values to match
+// against, the Condition is always false, and takes the form "1=0".
+func In(obj SQLTableNamer, property any, values ...any) Condition {
+	return in(column(obj, property), "IN", "1=0", values)
+}
+
+// NotIn returns a Condition in the form of "column NOT IN (value, value, value)". It takes
+// the same arguments as In, but if there are no values to match against, the Condition is
+// always true, and takes the form "1=1".
+func NotIn(obj SQLTableNamer, property any, values ...any) Condition {
+	return in(column(obj, property), "NOT IN", "1=1", values)
+}
+
+func in(column, operator, empty string, values []any) Condition {
+	if len(values) == 1 {
+		if sub, ok := values[0].(*Query); ok {
+			return Expression(column+" "+operator+" ?", sub)
+		}
+	}
+	values = expandValues(values)
+	if len(values) < 1 {
+		return Expression(empty)
+	}
+	return Expression(column+" "+operator+"("+Placeholders(len(values))+")", values...)
+}
+
+// expandValues returns `values` with the elements of any slices or arrays in
+// place of the slices or arrays themselves. []byte and driver.Valuer values are
+// single values to the database, so they're left as-is.
+func expandValues(values []any) []any {
+	res := make([]any, 0, len(values))
+	for _, value := range values {
+		if _, ok := value.(driver.Valuer); ok {
+			res = append(res, value)
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+			res = append(res, value)
+			continue
+		}
+		for i := 0; i < v.Len(); i++ {
+			res = append(res, v.Index(i).Interface())
+		}
+	}
+	return res
+}
+
+// Between returns a Condition in the form of "column BETWEEN ? AND ?", with `low` and `high`
+// as its arguments. `obj` and `property` are used to determine the column. `property` must
+// exactly match the name of a property on `obj`, or be a pointer to the field, like Col takes,
+// or the call will panic.
+func Between(obj SQLTableNamer, property any, low, high any) Condition {
+	return Expression(column(obj, property)+" BETWEEN ? AND ?", low, high)
+}
+
+// IsNull returns a Condition in the form of "column IS NULL". `obj` and `property` are used to
+// determine the column, like Between uses them.
+func IsNull(obj SQLTableNamer, property any) Condition {
+	return Expression(column(obj, property) + " IS NULL")
+}
+
+// IsNotNull returns a Condition in the form of "column IS NOT NULL". `obj` and `property` are
+// used to determine the column, like Between uses them.
+func IsNotNull(obj SQLTableNamer, property any) Condition {
+	return Expression(column(obj, property) + " IS NOT NULL")
+}
+
+// LikeMode is the part of a column that Like and ILike match their value against. See the
+// constants defined in this package for valid values.
+type LikeMode int
+
+const (
+	// LikePrefix matches columns that start with the value.
+	LikePrefix LikeMode = iota
+	// LikeSuffix matches columns that end with the value.
+	LikeSuffix
+	// LikeContains matches columns that contain the value anywhere.
+	LikeContains
+)
+
+// likeEscape is the character that escapes wildcards in the patterns built by
+// Like and ILike. A backslash would be simpler, but MySQL treats it as an escape
+// in string literals, too, so it can't be written the same way everywhere.
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// Like returns a Condition in the form of "column LIKE ? ESCAPE '!'", matching the column
+// against `value` in the way `mode` describes. `value` is matched literally: the `%` and `_`
+// wildcards, and the `!` escape character, are escaped in it. (SQL Server’s `[` wildcard isn’t.)
+// `obj` and `property` are used to determine the column, like Between uses them.
+func Like(obj SQLTableNamer, property any, value string, mode LikeMode) Condition {
+	return Expression(column(obj, property)+" LIKE ? ESCAPE '"+likeEscape+"'", likePattern(value, mode))
+}
+
+// ILike is like Like, but ignores case. It takes the form "column ILIKE ? ESCAPE '!'" for
+// Dialects that fill the ILiker interface, and "LOWER(column) LIKE ? ESCAPE '!'", with `value`
+// in lowercase, for Dialects that don’t.
+func ILike(obj SQLTableNamer, property any, value string, mode LikeMode) Condition {
+	return Expression(directive(directiveLower)+column(obj, property)+directive(directiveILike)+" ? ESCAPE '"+likeEscape+"'", likePattern(strings.ToLower(value), mode))
+}
+
+// likePattern returns a LIKE pattern matching `value` in the way `mode` describes.
+func likePattern(value string, mode LikeMode) string {
+	value = likeEscaper.Replace(value)
+	switch mode {
+	case LikePrefix:
+		return value + "%"
+	case LikeSuffix:
+		return "%" + value
+	}
+	return "%" + value + "%"
+}
+
+// And returns a Condition that is true when all of `conds` are true. If only one of
+// `conds` isn’t empty, it is returned as-is.
+func And(conds ...Condition) Condition {
+	return combine("AND", precedenceAnd, conds)
+}
+
+// Or returns a Condition that is true when any of `conds` are true. If only one of
+// `conds` isn’t empty, it is returned as-is.
+func Or(conds ...Condition) Condition {
+	return combine("OR", precedenceOr, conds)
+}
+
+// Not returns a Condition that is true when `cond` is false.
+func Not(cond Condition) Condition {
+	if cond.sql == "" {
+		return cond
+	}
+	return Condition{
+		sql:        "NOT " + cond.operand(precedenceNot),
+		args:       cond.args,
+		precedence: precedenceNot,
+		err:        cond.err,
+	}
+}
+
+func combine(operator string, prec int, conds []Condition) Condition {
+	var res Condition
+	var operands []Condition
+	for _, cond := range conds {
+		if cond.err != nil && res.err == nil {
+			res.err = cond.err
+		}
+		if cond.sql != "" {
+			operands = append(operands, cond)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return res
+	case 1:
+		// a single condition doesn't need the operator, or parentheses
+		operands[0].err = res.err
+		return operands[0]
+	}
+	sql := make([]string, 0, len(operands))
+	for _, cond := range operands {
+		sql = append(sql, cond.operand(prec))
+		res.args = append(res.args, cond.args...)
+	}
+	res.sql = strings.Join(sql, " "+operator+" ")
+	res.precedence = prec
+	return res
+}
+
+// operand returns the SQL of the Condition, wrapped in parentheses if it’s an
+// operand of an operator with a higher precedence than `prec`.
+func (c Condition) operand(prec int) string {
+	if c.precedence < prec {
+		return "(" + c.sql + ")"
+	}
+	return c.sql
+}
+
+// precedence returns the precedence of the loosest boolean operator outside of
+// parentheses, literals, and comments in `sql`. The AND of a BETWEEN isn't a
+// boolean operator. Whether backslashes escape characters in strings depends on
+// the Dialect, which isn't known yet, so `sql` is scanned both ways and the
+// looser precedence is used; unneeded parentheses are harmless.
+func precedence(sql string) int {
+	res := scanPrecedence(sql, false)
+	if escaped := scanPrecedence(sql, true); escaped < res {
+		return escaped
+	}
+	return res
+}
+
+func scanPrecedence(sql string, backslash bool) int {
+	res := precedenceAtom
+	var depth int
+	var between bool
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		end := i + 1
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isIdentStart(c) && (i == 0 || !isIdentChar(sql[i-1])):
+			for end < len(sql) && isIdentChar(sql[end]) {
+				end++
+			}
+			if depth != 0 {
+				break
+			}
+			switch strings.ToUpper(sql[i:end]) {
+			case "BETWEEN":
+				between = true
+			case "AND":
+				if between {
+					between = false
+				} else if res > precedenceAnd {
+					res = precedenceAnd
+				}
+			case "OR":
+				res = precedenceOr
+			}
+		default:
+			if e := literalEnd(sql, i, backslash); e > 0 {
+				end = e
+			}
+		}
+		i = end
+	}
+	return res
+}
+
+// condition adds `cond` to the Query’s buffer.
+func (q *Query) condition(cond Condition) *Query {
+	if cond.err != nil && q.err == nil {
+		q.err = cond.err
+	}
+	q.expressions = append(q.expressions, cond.sql)
+	q.args = append(q.args, cond.args...)
+	return q
+}
@@ -0,0 +1,144 @@
+package pan
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+type conditionTest struct {
+	cond     Condition
+	expected string
+	args     []any
+}
+
+func TestConditions(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	tests := []conditionTest{
+		{
+			cond:     And(Comparison(p, "ID", "=", 1), Comparison(p, "Title", "=", "a")),
+			expected: "id = ? AND title = ?",
+			args:     []any{1, "a"},
+		},
+		{
+			cond:     Or(Comparison(p, "ID", "=", 1), And(Comparison(p, "Title", "=", "a"), Comparison(p, "Body", "=", "b"))),
+			expected: "id = ? OR title = ? AND body = ?",
+			args:     []any{1, "a", "b"},
+		},
+		{
+			cond:     And(Or(Comparison(p, "ID", "=", 1), Comparison(p, "ID", "=", 2)), Comparison(p, "Title", "=", "a")),
+			expected: "(id = ? OR id = ?) AND title = ?",
+			args:     []any{1, 2, "a"},
+		},
+		{
+			cond:     And(Comparison(p, "ID", "=", 1), And(Comparison(p, "Title", "=", "a"), Comparison(p, "Body", "=", "b"))),
+			expected: "id = ? AND title = ? AND body = ?",
+			args:     []any{1, "a", "b"},
+		},
+		{
+			cond:     Not(Or(Comparison(p, "ID", "=", 1), In(p, "Author", 2, 3))),
+			expected: "NOT (id = ? OR author_id IN(?, ?))",
+			args:     []any{1, 2, 3},
+		},
+		{
+			cond:     Not(Comparison(p, "ID", "=", 1)),
+			expected: "NOT id = ?",
+			args:     []any{1},
+		},
+		{
+			cond:     And(Not(And(Comparison(p, "ID", "=", 1), Comparison(p, "ID", "=", 2))), Comparison(p, "Title", "=", "a")),
+			expected: "NOT (id = ? AND id = ?) AND title = ?",
+			args:     []any{1, 2, "a"},
+		},
+		{
+			cond:     And(Expression("created BETWEEN ? AND ?", 1, 2), Expression("title = 'a or b' OR (body = ? AND id = ?)", "b", 3)),
+			expected: "created BETWEEN ? AND ? AND (title = 'a or b' OR (body = ? AND id = ?))",
+			args:     []any{1, 2, "b", 3},
+		},
+		{
+			cond:     Or(Expression("a AND b"), Expression("c -- or d\n")),
+			expected: "a AND b OR c -- or d\n",
+		},
+		{
+			cond:     And(Condition{}, Or(Comparison(p, "ID", "=", 1), Comparison(p, "ID", "=", 2)), Not(Condition{})),
+			expected: "id = ? OR id = ?",
+			args:     []any{1, 2},
+		},
+		{
+			cond:     Or(),
+			expected: "",
+		},
+		{
+			cond:     And(Expression(`title = 'it\'s' OR id = ?`, 1), Comparison(p, "Body", "=", "b")),
+			expected: `(title = 'it\'s' OR id = ?) AND body = ?`,
+			args:     []any{1, "b"},
+		},
+		{
+			cond:     In(p, "ID", []int64{1, 2}, 3, [2]string{"a", "b"}),
+			expected: "id IN(?, ?, ?, ?, ?)",
+			args:     []any{int64(1), int64(2), 3, "a", "b"},
+		},
+		{
+			cond:     NotIn(p, "Body", []byte("a"), sql.NullString{String: "b", Valid: true}, []string{"c"}),
+			expected: "body NOT IN(?, ?, ?)",
+			args:     []any{[]byte("a"), sql.NullString{String: "b", Valid: true}, "c"},
+		},
+		{
+			cond:     And(Comparison(p, "Title", "=", "a"), In(p, "ID", []int{}), NotIn(p, "ID")),
+			expected: "title = ? AND 1=0 AND 1=1",
+			args:     []any{"a"},
+		},
+		{
+			cond:     NotIn(p, "ID", Select[testPost](SelectProperties("ID")).Where(Comparison(p, "Author", "=", 1)).Flush(" ")),
+			expected: "id NOT IN (SELECT id FROM test_data WHERE author_id = ?)",
+			args:     []any{1},
+		},
+		{
+			cond:     Or(Between(p, "Created", 1, 2), IsNull(p, "Modified"), IsNotNull(p, "Body")),
+			expected: "created BETWEEN ? AND ? OR modified IS NULL OR body IS NOT NULL",
+			args:     []any{1, 2},
+		},
+		{
+			cond:     And(Like(p, "Title", "50%_off!", LikeContains), Like(p, "Body", "a", LikePrefix), Like(p, "Body", "z", LikeSuffix)),
+			expected: "title LIKE ? ESCAPE '!' AND body LIKE ? ESCAPE '!' AND body LIKE ? ESCAPE '!'",
+			args:     []any{"%50!%!_off!!%", "a%", "%z"},
+		},
+	}
+	for pos, test := range tests {
+		if test.cond.err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, test.cond.err)
+		}
+		if test.cond.sql != test.expected {
+			t.Errorf("Test %d: expected `%s`, got `%s`", pos+1, test.expected, test.cond.sql)
+		}
+		if !reflect.DeepEqual(test.cond.args, test.args) {
+			t.Errorf("Test %d: expected args %v, got %v", pos+1, test.args, test.cond.args)
+		}
+	}
+}
+
+func TestWhereConditions(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	q := Select[testPost](SelectProperties("ID")).Where(Or(Comparison(p, "ID", "=", 1), Comparison(p, "ID", "=", 2)), Comparison(p, "Title", "=", "a"))
+	q.Where(Or(Comparison(p, "Author", "=", 3), Expression("body = :body", NamedArgs{"body": "b"})))
+	q.Where(And())
+	q.OrderBy(Column(p, "ID")).Flush(" ")
+	query, args, err := q.SQL(PostgreSQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	expected := "SELECT id FROM test_data WHERE (id = $1 OR id = $2) AND title = $3 AND (author_id = $4 OR body = $5) ORDER BY id;"
+	if query != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{1, 2, "a", 3, "b"}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	q = Select[testPost](SelectProperties("ID")).Where(Expression("title = :title", NamedArgs{"body": "b"})).Flush(" ")
+	if _, _, err := q.SQL(PostgreSQL); err != (ErrMissingNamedArg{Name: "title"}) {
+		t.Errorf("Expected %v, got %v", ErrMissingNamedArg{Name: "title"}, err)
+	}
+}
@@ -0,0 +1,729 @@
+package pan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL syntax a database expects. Queries are built without
+// knowing which database they'll run against; the Dialect passed to a Query’s SQL
+// method decides how its placeholders, quoted identifiers, and clauses are written.
+//
+// MySQL, PostgreSQL, SQLite, SQL Server, and Oracle are built in, but any type that fills the Dialect
+// interface can be used.
+type Dialect interface {
+	// Placeholder returns the placeholder for the nth argument of a query, counting
+	// from 1.
+	Placeholder(n int) string
+
+	// QuoteIdentifier returns `identifier`, quoted so it can be used as a table or
+	// column name.
+	QuoteIdentifier(identifier string) string
+
+	// LimitOffset returns the clause that limits a query to `limit` rows after
+	// skipping `offset` rows. Both `limit` and `offset` are placeholders, and
+	// either may be empty if the query doesn't set it.
+	LimitOffset(limit, offset string) string
+
+	// Terminator returns the string appended to the end of every query.
+	Terminator() string
+}
+
+// PlaceholderReuser is implemented by Dialects whose placeholders can refer to the same
+// argument more than once, like PostgreSQL’s `$1`. When a named parameter is used more than
+// once in an expression, Dialects that reuse placeholders repeat the placeholder, and others
+// repeat the argument.
+type PlaceholderReuser interface {
+	Dialect
+
+	// ReusesPlaceholders returns true if placeholders can be repeated.
+	ReusesPlaceholders() bool
+}
+
+// Upserter is implemented by Dialects that can resolve conflicts when inserting rows.
+// Queries built with Upsert can only be rendered with Dialects that fill it.
+type Upserter interface {
+	Dialect
+
+	// Upsert returns the keyword that starts an INSERT statement, and the clause that
+	// follows its values, for resolving conflicts on the `target` columns by setting the
+	// `update` columns to their inserted values. If `update` is empty, conflicting rows
+	// should be left alone.
+	Upsert(target, update []string) (insert, clause string)
+}
+
+// ConflictTargeter is implemented by Upserters that can only update conflicting rows when
+// they know which columns the conflict is on, like PostgreSQL’s ON CONFLICT DO UPDATE.
+// Rendering a Query built with Upsert that has no conflict target and updates conflicting
+// rows with one of them returns an ErrNoPrimaryKey error.
+type ConflictTargeter interface {
+	Upserter
+
+	// NeedsConflictTarget returns true if updating conflicting rows needs a target.
+	NeedsConflictTarget() bool
+}
+
+// Returner is implemented by Dialects that can return rows from INSERT, UPDATE, and DELETE
+// statements. Queries that use Returning can only be rendered with Dialects that fill it.
+type Returner interface {
+	Dialect
+
+	// Returning returns the keyword that precedes the columns to return.
+	Returning() string
+}
+
+// ArgLimiter is implemented by Dialects that limit how many arguments a single query can
+// have. InsertBatches uses it to decide how many rows to insert with each query.
+type ArgLimiter interface {
+	Dialect
+
+	// MaxArgs returns the most arguments a single query can have.
+	MaxArgs() int
+}
+
+// RowComparer is implemented by Dialects that can compare row values, like
+// "(a, b) > (?, ?)". Queries built using Keyset.Page use the expanded form of the
+// comparison, like "(a > ? OR a = ? AND b > ?)", when they're rendered with Dialects
+// that don’t fill it.
+type RowComparer interface {
+	Dialect
+
+	// ComparesRows returns true if row values can be compared.
+	ComparesRows() bool
+}
+
+// RecursiveWither is implemented by Dialects that don’t use WITH RECURSIVE to start common
+// table expressions that refer to themselves.
+type RecursiveWither interface {
+	Dialect
+
+	// RecursiveWith returns the keywords that start a WITH clause whose common table
+	// expressions may refer to themselves.
+	RecursiveWith() string
+}
+
+// TableAliaser is implemented by Dialects that don’t use AS to give a table an alias.
+type TableAliaser interface {
+	Dialect
+
+	// TableAlias returns the keyword that precedes a table’s alias, which may be empty.
+	TableAlias() string
+}
+
+// ILiker is implemented by Dialects that can match patterns case-insensitively using the
+// ILIKE operator. ILike compares the lowercase column using LIKE for Dialects that don’t
+// fill it.
+type ILiker interface {
+	Dialect
+
+	// ILikes returns true if patterns can be matched using ILIKE.
+	ILikes() bool
+}
+
+// OrderedPager is implemented by Dialects whose paging clause is only allowed after an
+// ORDER BY clause, like SQL Server’s OFFSET and FETCH. Rendering a Query that uses Limit or
+// Offset without an ORDER BY with one of them returns an ErrUnsupported error.
+type OrderedPager interface {
+	Dialect
+
+	// PagesOrdered returns true if paging needs an ORDER BY clause.
+	PagesOrdered() bool
+}
+
+// BackslashEscaper is implemented by Dialects whose string literals use backslashes to
+// escape the character after them, like MySQL’s do unless the NO_BACKSLASH_ESCAPES mode
+// is set. A `?` inside a string like 'it\'s ?' is only known not to be a placeholder when
+// the Query is rendered, so expressions using named placeholders or subqueries, which are
+// compiled before then, shouldn’t rely on it.
+type BackslashEscaper interface {
+	Dialect
+
+	// EscapesBackslashes returns true if backslashes escape characters in strings.
+	EscapesBackslashes() bool
+}
+
+// ErrUnsupported is returned when a Query uses SQL that the Dialect it’s rendered with
+// doesn’t support. The Feature property describes the SQL that isn’t supported.
+type ErrUnsupported struct {
+	Feature string
+}
+
+// Error fills the error interface.
+func (e ErrUnsupported) Error() string {
+	return fmt.Sprintf("Dialect doesn't support %s.", e.Feature)
+}
+
+// Is returns true if `target` is an ErrUnsupported, whatever its Feature, so
+// errors.Is(err, ErrUnsupported{}) checks for any unsupported SQL.
+func (e ErrUnsupported) Is(target error) bool {
+	_, ok := target.(ErrUnsupported)
+	return ok
+}
+
+var (
+	// MySQL is the Dialect for MySQL and MariaDB.
+	MySQL Dialect = mysqlDialect{}
+
+	// PostgreSQL is the Dialect for PostgreSQL.
+	PostgreSQL Dialect = postgreSQLDialect{}
+
+	// SQLite is the Dialect for SQLite. It assumes queries can have at most 999
+	// arguments, the limit of SQLite builds before 3.32.0; pass InsertBatches a
+	// higher limit for newer builds.
+	SQLite Dialect = sqliteDialect{}
+
+	// SQLServer is the Dialect for Microsoft SQL Server. SQL Server pages results
+	// using OFFSET and FETCH, which are only allowed after an ORDER BY clause, so
+	// Queries that use Limit or Offset must also be ordered, or rendering them
+	// returns an ErrUnsupported error.
+	SQLServer Dialect = sqlServerDialect{}
+
+	// Oracle is the Dialect for Oracle Database. Oracle drivers reject a trailing
+	// semicolon, so Queries rendered with Oracle don't have one.
+	Oracle Dialect = oracleDialect{}
+)
+
+// standardLimitOffset returns the LIMIT and OFFSET clause understood by
+// MySQL, PostgreSQL, and SQLite.
+func standardLimitOffset(limit, offset string) string {
+	var clauses []string
+	if limit != "" {
+		clauses = append(clauses, "LIMIT "+limit)
+	}
+	if offset != "" {
+		clauses = append(clauses, "OFFSET "+offset)
+	}
+	return strings.Join(clauses, " ")
+}
+
+// onConflict returns the ON CONFLICT clause understood by PostgreSQL and
+// SQLite.
+func onConflict(target, update []string) (string, string) {
+	clause := "ON CONFLICT"
+	if len(target) > 0 {
+		clause += " (" + strings.Join(target, ", ") + ")"
+	}
+	if len(update) < 1 {
+		return "INSERT", clause + " DO NOTHING"
+	}
+	sets := make([]string, 0, len(update))
+	for _, column := range update {
+		sets = append(sets, column+" = EXCLUDED."+column)
+	}
+	return "INSERT", clause + " DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string { return "?" }
+
+func (mysqlDialect) QuoteIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+func (mysqlDialect) LimitOffset(limit, offset string) string {
+	return standardLimitOffset(limit, offset)
+}
+
+func (mysqlDialect) Terminator() string { return ";" }
+
+func (mysqlDialect) MaxArgs() int { return 65535 }
+
+func (mysqlDialect) ComparesRows() bool { return true }
+
+func (mysqlDialect) EscapesBackslashes() bool { return true }
+
+func (mysqlDialect) Upsert(_, update []string) (string, string) {
+	if len(update) < 1 {
+		return "INSERT IGNORE", ""
+	}
+	sets := make([]string, 0, len(update))
+	for _, column := range update {
+		sets = append(sets, column+" = VALUES("+column+")")
+	}
+	return "INSERT", "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+type postgreSQLDialect struct{}
+
+func (postgreSQLDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgreSQLDialect) QuoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (postgreSQLDialect) LimitOffset(limit, offset string) string {
+	return standardLimitOffset(limit, offset)
+}
+
+func (postgreSQLDialect) Terminator() string { return ";" }
+
+func (postgreSQLDialect) MaxArgs() int { return 65535 }
+
+func (postgreSQLDialect) ReusesPlaceholders() bool { return true }
+
+func (postgreSQLDialect) ComparesRows() bool { return true }
+
+func (postgreSQLDialect) ILikes() bool { return true }
+
+func (postgreSQLDialect) Upsert(target, update []string) (string, string) {
+	return onConflict(target, update)
+}
+
+func (postgreSQLDialect) NeedsConflictTarget() bool { return true }
+
+func (postgreSQLDialect) Returning() string { return "RETURNING" }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string { return "?" }
+
+func (sqliteDialect) QuoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (sqliteDialect) LimitOffset(limit, offset string) string {
+	return standardLimitOffset(limit, offset)
+}
+
+func (sqliteDialect) Terminator() string { return ";" }
+
+func (sqliteDialect) MaxArgs() int { return 999 }
+
+func (sqliteDialect) ComparesRows() bool { return true }
+
+func (sqliteDialect) Upsert(target, update []string) (string, string) {
+	return onConflict(target, update)
+}
+
+func (sqliteDialect) NeedsConflictTarget() bool { return true }
+
+func (sqliteDialect) Returning() string { return "RETURNING" }
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Placeholder(n int) string { return "@p" + strconv.Itoa(n) }
+
+func (sqlServerDialect) QuoteIdentifier(identifier string) string {
+	return "[" + strings.ReplaceAll(identifier, "]", "]]") + "]"
+}
+
+func (sqlServerDialect) LimitOffset(limit, offset string) string {
+	if offset == "" {
+		offset = "0"
+	}
+	clause := "OFFSET " + offset + " ROWS"
+	if limit != "" {
+		clause += " FETCH NEXT " + limit + " ROWS ONLY"
+	}
+	return clause
+}
+
+func (sqlServerDialect) Terminator() string { return ";" }
+
+func (sqlServerDialect) MaxArgs() int { return 2100 }
+
+func (sqlServerDialect) ReusesPlaceholders() bool { return true }
+
+func (sqlServerDialect) RecursiveWith() string { return "WITH" }
+
+func (sqlServerDialect) PagesOrdered() bool { return true }
+
+type oracleDialect struct{}
+
+func (oracleDialect) Placeholder(n int) string { return ":" + strconv.Itoa(n) }
+
+func (oracleDialect) QuoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+func (oracleDialect) LimitOffset(limit, offset string) string {
+	var clauses []string
+	if offset != "" {
+		clauses = append(clauses, "OFFSET "+offset+" ROWS")
+	}
+	if limit != "" {
+		clauses = append(clauses, "FETCH FIRST "+limit+" ROWS ONLY")
+	}
+	return strings.Join(clauses, " ")
+}
+
+func (oracleDialect) Terminator() string { return "" }
+
+func (oracleDialect) MaxArgs() int { return 65535 }
+
+func (oracleDialect) TableAlias() string { return "" }
+
+func (oracleDialect) RecursiveWith() string { return "WITH" }
+
+// renderer turns the tokens of a Query into SQL for a Dialect.
+type renderer struct {
+	dialect Dialect
+	args    []any
+
+	// bind returns the SQL to use in place of the argument at position `pos`
+	// in args.
+	bind func(pos int) string
+
+	// out holds the arguments for the rendered SQL, in the order their
+	// placeholders were written.
+	out []any
+
+	// reuse is true if references to earlier arguments should repeat the
+	// earlier argument's placeholder, and numbers holds the placeholder
+	// number each argument in args was bound to.
+	reuse   bool
+	numbers map[int]int
+
+	// ordered holds whether an ORDER BY clause has been written, for each level
+	// of parentheses the rendered SQL is in.
+	ordered []bool
+}
+
+func newRenderer(d Dialect, args []any) *renderer {
+	r := &renderer{dialect: d, args: args, numbers: map[int]int{}, ordered: []bool{false}}
+	if reuser, ok := d.(PlaceholderReuser); ok {
+		r.reuse = reuser.ReusesPlaceholders()
+	}
+	r.bind = func(pos int) string {
+		r.out = append(r.out, r.args[pos])
+		r.numbers[pos] = len(r.out)
+		return r.dialect.Placeholder(len(r.out))
+	}
+	return r
+}
+
+func (r *renderer) render(tokens []token) (string, error) {
+	var res strings.Builder
+	var pos int
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.kind {
+		case tokenText:
+			r.track(tok.value)
+			res.WriteString(tok.value)
+		case tokenPlaceholder:
+			res.WriteString(r.bind(pos))
+		case tokenEscaped:
+			// a literal question mark can't be told apart from a placeholder
+			// by databases whose placeholders are question marks
+			if r.dialect.Placeholder(1) == "?" {
+				return "", ErrUnsupported{Feature: "literal question marks"}
+			}
+			res.WriteString("?")
+		case tokenDirective:
+			name, payload := tok.directive()
+			switch name {
+			case directiveIdent:
+				res.WriteString(r.dialect.QuoteIdentifier(payload))
+			case directiveRef:
+				back, _ := strconv.Atoi(payload)
+				res.WriteString(r.ref(pos-back, pos))
+			case directiveKeyset:
+				operator, expanded, columns := decodeKeyset(payload)
+				res.WriteString(r.keyset(pos, operator, expanded, columns))
+			case directiveLimit, directiveOffset:
+				if pager, ok := r.dialect.(OrderedPager); ok && pager.PagesOrdered() && !r.ordered[len(r.ordered)-1] {
+					return "", ErrUnsupported{Feature: "LIMIT and OFFSET without ORDER BY"}
+				}
+				limit, offset := -1, -1
+				if name == directiveLimit {
+					limit = pos
+				} else {
+					offset = pos
+				}
+				// a LIMIT and OFFSET next to each other are rendered as one
+				// clause, as some Dialects need to write them together
+				if next := pagingPartner(tokens, i, name); next > i {
+					if limit < 0 {
+						limit = pos + 1
+					} else {
+						offset = pos + 1
+					}
+					pos++
+					i = next
+				}
+				res.WriteString(r.limitOffset(limit, offset))
+			case directiveReturning:
+				returner, ok := r.dialect.(Returner)
+				if !ok {
+					return "", ErrUnsupported{Feature: "RETURNING"}
+				}
+				res.WriteString(returner.Returning())
+			case directiveRecursive:
+				keyword := "WITH RECURSIVE"
+				if wither, ok := r.dialect.(RecursiveWither); ok {
+					keyword = wither.RecursiveWith()
+				}
+				res.WriteString(keyword)
+			case directiveLower, directiveILike:
+				open, operator := "LOWER(", ") LIKE"
+				if iliker, ok := r.dialect.(ILiker); ok && iliker.ILikes() {
+					open, operator = "", " ILIKE"
+				}
+				if name == directiveLower {
+					res.WriteString(open)
+				} else {
+					res.WriteString(operator)
+				}
+			case directiveAlias:
+				keyword := "AS"
+				if aliaser, ok := r.dialect.(TableAliaser); ok {
+					keyword = aliaser.TableAlias()
+				}
+				if keyword == "" {
+					// don't leave the space before the keyword doubled up
+					trimmed := strings.TrimRight(res.String(), " ")
+					res.Reset()
+					res.WriteString(trimmed)
+				}
+				res.WriteString(keyword)
+			case directiveInsert, directiveConflict:
+				upserter, ok := r.dialect.(Upserter)
+				if !ok {
+					return "", ErrUnsupported{Feature: "resolving INSERT conflicts"}
+				}
+				target, update := decodeConflict(payload)
+				if targeter, ok := upserter.(ConflictTargeter); ok && targeter.NeedsConflictTarget() && len(target) < 1 && len(update) > 0 {
+					return "", ErrNoPrimaryKey
+				}
+				insert, clause := upserter.Upsert(target, update)
+				if name == directiveInsert {
+					res.WriteString(insert)
+				} else if clause != "" {
+					res.WriteString(clause)
+				} else {
+					// don't leave the space before the clause dangling
+					trimmed := strings.TrimRight(res.String(), " ")
+					res.Reset()
+					res.WriteString(trimmed)
+				}
+			}
+		}
+		pos += tok.args()
+	}
+	return res.String(), nil
+}
+
+// track updates which levels of parentheses have an ORDER BY clause, after the
+// text `sql` is written. String literals, quoted identifiers, and comments are
+// skipped.
+func (r *renderer) track(sql string) {
+	backslash := escapesBackslashes(r.dialect)
+	for i := 0; i < len(sql); i++ {
+		if end := literalEnd(sql, i, backslash); end > 0 {
+			i = end - 1
+			continue
+		}
+		switch c := sql[i]; {
+		case c == '(':
+			r.ordered = append(r.ordered, false)
+		case c == ')':
+			if len(r.ordered) > 1 {
+				r.ordered = r.ordered[:len(r.ordered)-1]
+			}
+		case isIdentStart(c) && (i == 0 || !isIdentChar(sql[i-1])):
+			end := i
+			for end < len(sql) && isIdentChar(sql[end]) {
+				end++
+			}
+			if strings.EqualFold(sql[i:end], "ORDER") {
+				rest := strings.TrimLeft(sql[end:], " \t\r\n")
+				if len(rest) >= 2 && strings.EqualFold(rest[:2], "BY") && (len(rest) == 2 || !isIdentChar(rest[2])) {
+					r.ordered[len(r.ordered)-1] = true
+				}
+			}
+			i = end - 1
+		}
+	}
+}
+
+// encodeConflict returns the payload of an insert or conflict directive.
+func encodeConflict(target, update []string) string {
+	return strings.Join(target, ",") + ";" + strings.Join(update, ",")
+}
+
+// decodeConflict returns the target and update columns from the payload of
+// an insert or conflict directive.
+func decodeConflict(payload string) (target, update []string) {
+	t, u, _ := strings.Cut(payload, ";")
+	if t != "" {
+		target = strings.Split(t, ",")
+	}
+	if u != "" {
+		update = strings.Split(u, ",")
+	}
+	return target, update
+}
+
+// encodeKeyset returns the payload of a keyset directive.
+func encodeKeyset(operator string, expanded bool, columns []string) string {
+	var flag string
+	if expanded {
+		flag = "expanded"
+	}
+	return operator + ";" + flag + ";" + strings.Join(columns, ",")
+}
+
+// decodeKeyset returns the operator, whether the comparison must be expanded,
+// and the columns from the payload of a keyset directive.
+func decodeKeyset(payload string) (operator string, expanded bool, columns []string) {
+	parts := strings.SplitN(payload, ";", 3)
+	if len(parts) < 3 {
+		return "", false, nil
+	}
+	if parts[2] != "" {
+		columns = strings.Split(parts[2], ",")
+	}
+	return parts[0], parts[1] == "expanded", columns
+}
+
+// ref returns the SQL for a reference to the argument at position `first`,
+// repeating its placeholder if the Dialect can refer to it again, or binding
+// the argument at position `pos` otherwise.
+func (r *renderer) ref(first, pos int) string {
+	if n, ok := r.numbers[first]; r.reuse && ok {
+		return r.dialect.Placeholder(n)
+	}
+	return r.bind(pos)
+}
+
+// keyset renders the Condition selecting the rows after the row whose `columns` have the
+// arguments starting at position `pos`. Row values are compared if the Dialect fills the
+// RowComparer interface and the comparison doesn't need to be expanded.
+func (r *renderer) keyset(pos int, operator string, expanded bool, columns []string) string {
+	comparer, ok := r.dialect.(RowComparer)
+	if len(columns) == 1 || (ok && comparer.ComparesRows() && !expanded) {
+		placeholders := make([]string, 0, len(columns))
+		for i := range columns {
+			placeholders = append(placeholders, r.bind(pos+i))
+		}
+		if len(columns) == 1 {
+			return columns[0] + " " + operator + " " + placeholders[0]
+		}
+		return "(" + strings.Join(columns, ", ") + ") " + operator + " (" + strings.Join(placeholders, ", ") + ")"
+	}
+	// each column is compared to its value after the columns before it are
+	// found to be equal, so each value after its first use is a reference
+	terms := make([]string, 0, len(columns))
+	for i, column := range columns {
+		var term strings.Builder
+		for prev := 0; prev < i; prev++ {
+			term.WriteString(columns[prev] + " = " + r.ref(pos+prev, pos+prev) + " AND ")
+		}
+		term.WriteString(column + " " + operator + " " + r.bind(pos+i))
+		terms = append(terms, term.String())
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// pagingPartner returns the index of the limit or offset directive that pairs
+// with the paging directive at `i`, or -1 if there isn't one.
+func pagingPartner(tokens []token, i int, name string) int {
+	want := directiveOffset
+	if name == directiveOffset {
+		want = directiveLimit
+	}
+	for j := i + 1; j < len(tokens); j++ {
+		if tokens[j].kind == tokenText && strings.TrimSpace(tokens[j].value) == "" {
+			continue
+		}
+		if tokens[j].kind != tokenDirective {
+			return -1
+		}
+		if n, _ := tokens[j].directive(); n == want {
+			return j
+		}
+		return -1
+	}
+	return -1
+}
+
+// limitOffset renders the Dialect's paging clause for the arguments at
+// positions `limit` and `offset`, either of which may be -1 if unset. The
+// arguments are bound in the order the Dialect writes them.
+func (r *renderer) limitOffset(limit, offset int) string {
+	const limitMark, offsetMark = "\x00limit\x00", "\x00offset\x00"
+	var l, o string
+	if limit >= 0 {
+		l = limitMark
+	}
+	if offset >= 0 {
+		o = offsetMark
+	}
+	clause := r.dialect.LimitOffset(l, o)
+	var res strings.Builder
+	for {
+		li, oi := -1, -1
+		if limit >= 0 {
+			li = strings.Index(clause, limitMark)
+		}
+		if offset >= 0 {
+			oi = strings.Index(clause, offsetMark)
+		}
+		if li < 0 && oi < 0 {
+			break
+		}
+		if li >= 0 && (oi < 0 || li < oi) {
+			res.WriteString(clause[:li])
+			res.WriteString(r.bind(limit))
+			clause = clause[li+len(limitMark):]
+		} else {
+			res.WriteString(clause[:oi])
+			res.WriteString(r.bind(offset))
+			clause = clause[oi+len(offsetMark):]
+		}
+	}
+	res.WriteString(clause)
+	return res.String()
+}
+
+// SQL returns an SQL string that can be passed to the database `d` describes to
+// execute your query, along with the arguments to pass with it. The arguments are
+// in the order `d` expects them, which may not be the order they were added to the
+// Query in; after SQL is called, Args returns them in the same order.
+//
+// If the number of placeholders do not match the number of arguments provided to
+// your Query, an ErrWrongNumberArgs error will be returned. If there are still
+// expressions left in the buffer (meaning the Flush method wasn't called) an
+// ErrNeedsFlush error will be returned. If the Query uses SQL that `d` doesn’t
+// support, an ErrUnsupported error will be returned.
+func (q *Query) SQL(d Dialect) (string, []any, error) {
+	q.rendered = d
+	if len(q.expressions) != 0 {
+		return "", nil, ErrNeedsFlush
+	}
+	if err := q.checkCounts(d); err != nil {
+		return "", nil, err
+	}
+	sql, args := q.materialize()
+	positional, byName := splitArgs(args)
+	r := newRenderer(d, positional)
+	sql, err := r.render(lex(sql, escapesBackslashes(d)))
+	if err != nil {
+		return "", nil, err
+	}
+	return sql + d.Terminator(), append(r.out, byName...), nil
+}
+
+// String returns a version of your Query with all the arguments in the place of their
+// placeholders. It does not do any sanitization, and is vulnerable to SQL injection.
+// It is meant as a debugging aid, not to be executed. The string will almost certainly
+// not be valid SQL.
+func (q *Query) String() string {
+	sql, args := q.materialize()
+	args, _ = splitArgs(args)
+	r := newRenderer(PostgreSQL, args)
+	r.reuse = false
+	r.bind = func(pos int) string {
+		var arg any
+		arg = "!{MISSING}"
+		if len(args) > pos {
+			arg = args[pos]
+		}
+		return fmt.Sprintf("%v", arg)
+	}
+	res, _ := r.render(lex(sql, false))
+	return res
+}
@@ -0,0 +1,288 @@
+package pan
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// reversedDialect is a Dialect that writes OFFSET before LIMIT, to test that
+// arguments follow the order the Dialect writes them in.
+type reversedDialect struct{}
+
+func (reversedDialect) Placeholder(n int) string { return ":" + strconv.Itoa(n) }
+
+func (reversedDialect) QuoteIdentifier(identifier string) string { return "[" + identifier + "]" }
+
+func (reversedDialect) LimitOffset(limit, offset string) string {
+	res := "SKIP " + offset + " TAKE " + limit
+	if offset == "" {
+		res = "TAKE " + limit
+	}
+	return res
+}
+
+func (reversedDialect) Terminator() string { return "" }
+
+type dialectTest struct {
+	query    *Query
+	dialect  Dialect
+	expected string
+	args     []any
+}
+
+func TestDialects(t *testing.T) {
+	t.Parallel()
+	p := testPost{ID: 123}
+	tests := []dialectTest{
+		{
+			query:    New("SELECT "+Columns(p, FlagQuoted).String()+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", p.ID).Limit(10).Offset(20).Flush(" "),
+			dialect:  MySQL,
+			expected: "SELECT `id`, `title`, `author_id`, `body`, `created`, `modified` FROM test_data WHERE id = ? LIMIT ? OFFSET ?;",
+			args:     []any{123, int64(10), int64(20)},
+		},
+		{
+			query:    New("SELECT " + Column(p, "ID", FlagFull, FlagQuoted) + " FROM " + Table(p)).Limit(10).Offset(20).Flush(" "),
+			dialect:  PostgreSQL,
+			expected: `SELECT "test_data"."id" FROM test_data LIMIT $1 OFFSET $2;`,
+			args:     []any{int64(10), int64(20)},
+		},
+		{
+			query:    New("SELECT "+Column(p, "ID", FlagQuoted)+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", p.ID).Limit(10).Offset(20).Flush(" "),
+			dialect:  reversedDialect{},
+			expected: "SELECT [id] FROM test_data WHERE id = :1 SKIP :2 TAKE :3",
+			args:     []any{123, int64(20), int64(10)},
+		},
+		{
+			query:    New("SELECT * FROM " + Table(p)).Limit(10).Flush(" "),
+			dialect:  reversedDialect{},
+			expected: "SELECT * FROM test_data TAKE :1",
+			args:     []any{int64(10)},
+		},
+		{
+			query:    New("SELECT "+Columns(p, FlagFull, FlagBracketed).String()+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", p.ID).OrderBy(Column(p, "ID")).Limit(10).Offset(20).Flush(" "),
+			dialect:  SQLServer,
+			expected: "SELECT [test_data].[id], [test_data].[title], [test_data].[author_id], [test_data].[body], [test_data].[created], [test_data].[modified] FROM test_data WHERE id = @p1 ORDER BY id OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY;",
+			args:     []any{123, int64(20), int64(10)},
+		},
+		{
+			query:    New("SELECT " + Column(p, "ID", FlagQuoted) + " FROM " + Table(p)).OrderBy(Column(p, "ID")).Limit(10).Flush(" "),
+			dialect:  SQLServer,
+			expected: "SELECT [id] FROM test_data ORDER BY id OFFSET 0 ROWS FETCH NEXT @p1 ROWS ONLY;",
+			args:     []any{int64(10)},
+		},
+		{
+			query:    New("SELECT * FROM " + Table(p)).OrderBy(Column(p, "ID")).Offset(20).Flush(" "),
+			dialect:  SQLServer,
+			expected: "SELECT * FROM test_data ORDER BY id OFFSET @p1 ROWS;",
+			args:     []any{int64(20)},
+		},
+		{
+			query:    New("SELECT "+Column(p, "ID", FlagQuoted)+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", p.ID).OrderBy(Column(p, "ID")).Limit(10).Offset(20).Flush(" "),
+			dialect:  Oracle,
+			expected: `SELECT "id" FROM test_data WHERE id = :1 ORDER BY id OFFSET :2 ROWS FETCH FIRST :3 ROWS ONLY`,
+			args:     []any{123, int64(20), int64(10)},
+		},
+		{
+			query:    New("SELECT * FROM " + Table(p)).OrderBy(Column(p, "ID")).Limit(10).Flush(" "),
+			dialect:  Oracle,
+			expected: "SELECT * FROM test_data ORDER BY id FETCH FIRST :1 ROWS ONLY",
+			args:     []any{int64(10)},
+		},
+		{
+			query:    Select[testPost](SelectAs("p"), SelectProperties("ID"), SelectFlags(FlagFull, FlagQuoted)).Flush(" "),
+			dialect:  Oracle,
+			expected: `SELECT "p"."id" FROM "test_data" "p"`,
+		},
+		{
+			query:    New("SELECT n FROM nums").WithRecursive("nums", New("SELECT").Expression("? UNION ALL SELECT n + 1 FROM nums WHERE n < ?", 1, 5).Flush(" "), "n").Flush(" "),
+			dialect:  SQLServer,
+			expected: "WITH nums (n) AS (SELECT @p1 UNION ALL SELECT n + 1 FROM nums WHERE n < @p2) SELECT n FROM nums;",
+			args:     []any{1, 5},
+		},
+	}
+	for pos, test := range tests {
+		sql, args, err := test.query.SQL(test.dialect)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, err)
+		}
+		if sql != test.expected {
+			t.Errorf("Test %d: expected `%s`, got `%s`", pos+1, test.expected, sql)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test %d: expected args %v, got %v", pos+1, test.args, args)
+		}
+	}
+}
+
+func TestDialectErrors(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data").Limit(10)
+	if _, _, err := q.SQL(SQLite); err != ErrNeedsFlush {
+		t.Errorf("Expected %v, got %v", ErrNeedsFlush, err)
+	}
+	q.Flush(" ")
+	q.args = q.args[:0]
+	if _, _, err := q.SQL(SQLite); err == nil {
+		t.Errorf("Expected an error, got nil")
+	}
+}
+
+func TestStringDirectives(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	q := New("SELECT " + Column(p, "ID", FlagQuoted) + " FROM " + Table(p)).Limit(10).Offset(20).Flush(" ")
+	if q.String() != `SELECT "id" FROM test_data LIMIT 10 OFFSET 20` {
+		t.Errorf("Expected `%s`, got `%s`", `SELECT "id" FROM test_data LIMIT 10 OFFSET 20`, q.String())
+	}
+}
+
+func TestArgsFollowRender(t *testing.T) {
+	t.Parallel()
+	type argsTest struct {
+		render   func(*Query) (string, error)
+		expected string
+		args     []any
+	}
+	tests := []argsTest{
+		{
+			render:   (*Query).MySQLString,
+			expected: "SELECT * FROM test_data WHERE id = ? LIMIT ? OFFSET ?;",
+			args:     []any{1, int64(10), int64(20)},
+		},
+		{
+			render:   (*Query).PostgreSQLString,
+			expected: "SELECT * FROM test_data WHERE id = $1 LIMIT $2 OFFSET $3;",
+			args:     []any{1, int64(10), int64(20)},
+		},
+		{
+			render:   (*Query).SQLiteString,
+			expected: "SELECT * FROM test_data WHERE id = ? LIMIT ? OFFSET ?;",
+			args:     []any{1, int64(10), int64(20)},
+		},
+	}
+	for pos, test := range tests {
+		q := New("SELECT * FROM test_data").Where().Expression("id = ?", 1).Offset(20).Limit(10).Flush(" ")
+		if !reflect.DeepEqual(q.Args(), []any{1, int64(20), int64(10)}) {
+			t.Errorf("Test %d: expected args in the order they were added before rendering, got %v", pos+1, q.Args())
+		}
+		query, err := test.render(q)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, err)
+		}
+		if query != test.expected {
+			t.Errorf("Test %d: expected `%s`, got `%s`", pos+1, test.expected, query)
+		}
+		if !reflect.DeepEqual(q.Args(), test.args) {
+			t.Errorf("Test %d: expected args %v, got %v", pos+1, test.args, q.Args())
+		}
+	}
+
+	// named parameters used more than once are only bound once by PostgreSQL
+	q := New("SELECT * FROM test_data WHERE").Expression("author_id = :user OR editor_id = :user", NamedArgs{"user": 1}).Flush(" ")
+	if _, err := q.PostgreSQLString(); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1}) {
+		t.Errorf("Expected args %v, got %v", []any{1}, q.Args())
+	}
+	if _, err := q.MySQLString(); err != nil {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+	if !reflect.DeepEqual(q.Args(), []any{1, 1}) {
+		t.Errorf("Expected args %v, got %v", []any{1, 1}, q.Args())
+	}
+}
+
+func TestEscapedQuestionMarks(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM t WHERE").Expression("data ?? ? AND data ??| ?", "a", "b").Flush(" ")
+	type escapedTest struct {
+		dialect  Dialect
+		expected string
+	}
+	tests := []escapedTest{
+		{dialect: PostgreSQL, expected: "SELECT * FROM t WHERE data ? $1 AND data ?| $2;"},
+		{dialect: SQLServer, expected: "SELECT * FROM t WHERE data ? @p1 AND data ?| @p2;"},
+		{dialect: Oracle, expected: "SELECT * FROM t WHERE data ? :1 AND data ?| :2"},
+		{dialect: MySQL},
+		{dialect: SQLite},
+	}
+	for pos, test := range tests {
+		query, args, err := q.SQL(test.dialect)
+		if test.expected == "" {
+			if _, ok := err.(ErrUnsupported); !ok {
+				t.Errorf("Test %d: expected an ErrUnsupported error, got %v", pos+1, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, err)
+		}
+		if query != test.expected {
+			t.Errorf("Test %d: expected `%s`, got `%s`", pos+1, test.expected, query)
+		}
+		if !reflect.DeepEqual(args, []any{"a", "b"}) {
+			t.Errorf("Test %d: expected args %v, got %v", pos+1, []any{"a", "b"}, args)
+		}
+	}
+}
+
+func TestSQLServerStringArgs(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data ORDER BY id").Limit(10).Offset(20).Flush(" ")
+	query, err := q.SQLServerString()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	expected := "SELECT * FROM test_data ORDER BY id OFFSET @p1 ROWS FETCH NEXT @p2 ROWS ONLY;"
+	if query != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, query)
+	}
+	if !reflect.DeepEqual(q.Args(), []any{int64(20), int64(10)}) {
+		t.Errorf("Expected args %v, got %v", []any{int64(20), int64(10)}, q.Args())
+	}
+}
+
+func TestSQLServerPagingNeedsOrder(t *testing.T) {
+	t.Parallel()
+	unordered := []*Query{
+		New("SELECT * FROM test_data").Limit(10).Flush(" "),
+		New("SELECT * FROM test_data WHERE title = 'ORDER BY'").Offset(20).Flush(" "),
+		New("SELECT ROW_NUMBER() OVER (ORDER BY id) FROM test_data").Limit(10).Offset(20).Flush(" "),
+	}
+	for pos, q := range unordered {
+		if _, err := q.SQLServerString(); !errors.Is(err, ErrUnsupported{}) {
+			t.Errorf("Test %d: expected an ErrUnsupported error, got %v", pos+1, err)
+		}
+		if _, err := q.PostgreSQLString(); err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, err)
+		}
+	}
+	q := New("SELECT * FROM (SELECT * FROM test_data ORDER BY id OFFSET 0 ROWS) AS t ORDER BY title").Limit(10).Flush(" ")
+	query, err := q.SQLServerString()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	expected := "SELECT * FROM (SELECT * FROM test_data ORDER BY id OFFSET 0 ROWS) AS t ORDER BY title OFFSET 0 ROWS FETCH NEXT @p1 ROWS ONLY;"
+	if query != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, query)
+	}
+}
+
+func TestOracleStringArgs(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data ORDER BY id").Limit(10).Offset(20).Flush(" ")
+	query, err := q.OracleString()
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	expected := "SELECT * FROM test_data ORDER BY id OFFSET :1 ROWS FETCH FIRST :2 ROWS ONLY"
+	if query != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, query)
+	}
+	if !reflect.DeepEqual(q.Args(), []any{int64(20), int64(10)}) {
+		t.Errorf("Expected args %v, got %v", []any{int64(20), int64(10)}, q.Args())
+	}
+}
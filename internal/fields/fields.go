@@ -0,0 +1,93 @@
+// Package fields maps struct fields to the columns they represent. It’s shared by pan and
+// the pan-gen command, so the columns pan-gen generates always match the ones pan finds
+// using reflection.
+package fields
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	tagName = "sql_column" // The tag that will be read
+
+	tagPrimaryKey = "pk" // The tag option marking a primary key column
+)
+
+func validTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != rune([]byte("_")[0]) && c != rune([]byte(".")[0]) && c != rune([]byte("-")[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func toSnake(s string) string {
+	if s == "" {
+		return ""
+	}
+	snake := ""
+	prevWasLower := false
+	buf := make([]byte, 4)
+	for _, c := range s {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			continue
+		}
+		if unicode.IsLower(c) {
+			prevWasLower = true
+		} else if unicode.IsUpper(c) {
+			c = unicode.ToLower(c)
+			if prevWasLower {
+				snake += "_"
+			}
+			prevWasLower = false
+		}
+
+		n := utf8.EncodeRune(buf, c)
+		snake += string(buf[0:n])
+	}
+	return snake
+}
+
+// parseTag splits a tag into the column name and the options that follow
+// it, separated by commas.
+func parseTag(tag string) (string, []string) {
+	name, options, found := strings.Cut(tag, ",")
+	if !found {
+		return name, nil
+	}
+	return name, strings.Split(options, ",")
+}
+
+// IsPrimaryKey returns whether the struct field `f` is tagged as part of the
+// primary key.
+func IsPrimaryKey(f reflect.StructField) bool {
+	_, options := parseTag(f.Tag.Get(tagName))
+	for _, option := range options {
+		if option == tagPrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// Column returns the name of the column that the struct field `f` maps to,
+// or an empty string if the field is omitted from the columns using the `-`
+// tag. It doesn't check whether `f` is exported.
+func Column(f reflect.StructField) string {
+	// Get the SQL column name, from the tag or infer it
+	field, _ := parseTag(f.Tag.Get(tagName))
+	if field == "-" {
+		return ""
+	}
+	if field == "" || !validTag(field) {
+		field = toSnake(f.Name)
+	}
+	return field
+}
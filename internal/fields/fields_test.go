@@ -0,0 +1,44 @@
+package fields
+
+import "testing"
+
+var tags = map[string]bool{
+	"":          false,
+	"my_data":   true,
+	"my_data_☃": false,
+	"my,data":   false,
+}
+
+func TestValidTag(t *testing.T) {
+	t.Parallel()
+	for input, validity := range tags {
+		if validTag(input) != validity {
+			expectedValidity := "valid"
+			actualValidity := "valid"
+			if !validity {
+				actualValidity = "invalid"
+			}
+			if !validity {
+				expectedValidity = "invalid"
+			}
+			t.Errorf("Expected `%s` to be %s, was %s.", input, expectedValidity, actualValidity)
+		}
+	}
+}
+
+var camelToSnake = map[string]string{
+	"":          "",
+	"myColumn":  "my_column",
+	"MyColumn":  "my_column",
+	"Mycolumn":  "mycolumn",
+	"My☃Column": "my_column",
+}
+
+func TestCamelToSnake(t *testing.T) {
+	t.Parallel()
+	for input, expectedOutput := range camelToSnake {
+		if expectedOutput != toSnake(input) {
+			t.Errorf("Expected `%s` to be `%s`, was `%s`", input, expectedOutput, toSnake(input))
+		}
+	}
+}
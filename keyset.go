@@ -0,0 +1,160 @@
+package pan
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ErrInvalidCursor is returned when a cursor passed to a Keyset wasn’t returned by the
+// Keyset’s Cursor method.
+var ErrInvalidCursor = errors.New("invalid keyset pagination cursor")
+
+// Keyset paginates the rows of a table by the values of the columns they’re ordered by,
+// instead of by an offset. Each page picks up after the last row of the previous page,
+// identified by a cursor, so pages stay fast on large tables and don’t skip or repeat rows
+// when rows are inserted or deleted between requests.
+//
+// The columns of Properties, followed by the columns of the primary key as a tie-breaker,
+// must not be NULL, and together must uniquely identify a row.
+type Keyset struct {
+	// Table is the SQLTableNamer the rows are read into, used to find the columns
+	// and to decode cursors. It can be returned by Aliased.
+	Table SQLTableNamer
+
+	// Properties lists the properties the rows are ordered by. The properties tagged
+	// as the primary key of Table are added after them, unless they’re already
+	// included. Each property must exactly match the name of a property on Table,
+	// or Page and Cursor will panic.
+	Properties []string
+
+	// Descending orders the rows in descending order, instead of ascending.
+	Descending bool
+
+	// Expanded compares the columns one by one, like "a > ? OR a = ? AND b > ?",
+	// even if the Dialect can compare row values, like "(a, b) > (?, ?)". It’s
+	// meant for versions of MySQL and SQLite that can’t compare row values, or
+	// can’t use an index to do so.
+	Expanded bool
+}
+
+// properties returns the properties the rows are ordered by, including the
+// primary key.
+func (k Keyset) properties() []string {
+	properties := append([]string{}, k.Properties...)
+	for _, key := range primaryKeys(k.Table) {
+		var found bool
+		for _, property := range properties {
+			found = found || property == key
+		}
+		if !found {
+			properties = append(properties, key)
+		}
+	}
+	return properties
+}
+
+// Cursor returns an opaque token identifying `last`, the last row of a page, which
+// can be passed to Page to get the page that follows it. `last` must have the same
+// type as Table.
+func (k Keyset) Cursor(last SQLTableNamer) (string, error) {
+	properties := k.properties()
+	if len(properties) < 1 {
+		return "", ErrNoPrimaryKey
+	}
+	values := make([]any, 0, len(properties))
+	for _, property := range properties {
+		values = append(values, propertyValue(last, property))
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// decode returns the values of the properties encoded in `cursor`, with the
+// types of the properties of Table.
+func (k Keyset) decode(cursor string, properties []string) ([]any, error) {
+	encoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(encoded, &raw); err != nil || len(raw) != len(properties) {
+		return nil, ErrInvalidCursor
+	}
+	table, _ := unalias(k.Table)
+	t := reflect.TypeOf(table)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	row := reflect.New(t).Elem()
+	values := make([]any, 0, len(properties))
+	for pos, property := range properties {
+		field := row.FieldByName(property)
+		if !field.IsValid() {
+			panic("Field not found in type: " + property)
+		}
+		if err := json.Unmarshal(raw[pos], field.Addr().Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values = append(values, field.Interface())
+	}
+	return values, nil
+}
+
+// Page adds the expressions that select a page of at most `limit` rows to the Query’s
+// buffer: a Condition passed to Where that selects the rows after the row `cursor`
+// identifies, an ORDER BY clause, and a LIMIT clause. If `cursor` is empty, the first
+// page is selected. When the Query is rendered, the Condition compares row values if the
+// Dialect fills the RowComparer interface, and Expanded isn’t set. Because the Condition
+// is passed to Where, `q` must not call Where without Conditions.
+//
+// If `cursor` wasn’t returned by Cursor, an ErrInvalidCursor error will be returned when
+// the Query is rendered. If there are no properties to order by, because Properties is
+// empty and no properties of Table are tagged as its primary key, an ErrNoPrimaryKey error
+// will be returned when the Query is rendered.
+func (k Keyset) Page(q *Query, cursor string, limit int64) *Query {
+	properties := k.properties()
+	if len(properties) < 1 {
+		if q.err == nil {
+			q.err = ErrNoPrimaryKey
+		}
+		return q
+	}
+	columns := make([]string, 0, len(properties))
+	for _, property := range properties {
+		columns = append(columns, Column(k.Table, property))
+	}
+	if cursor != "" {
+		values, err := k.decode(cursor, properties)
+		if err != nil {
+			if q.err == nil {
+				q.err = err
+			}
+			return q
+		}
+		q.Where(k.after(columns, values))
+	}
+	for _, column := range columns {
+		if k.Descending {
+			q.OrderByDesc(column)
+		} else {
+			q.OrderBy(column)
+		}
+	}
+	return q.Limit(limit)
+}
+
+// after returns a Condition selecting the rows that follow the row whose
+// `columns` have `values`. The comparison depends on the Dialect, so it's
+// left to the renderer.
+func (k Keyset) after(columns []string, values []any) Condition {
+	operator := ">"
+	if k.Descending {
+		operator = "<"
+	}
+	return Expression(directive(directiveKeyset, encodeKeyset(operator, k.Expanded, columns)), values...)
+}
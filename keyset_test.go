@@ -0,0 +1,174 @@
+package pan
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeysetPage(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	last := testPost{ID: 7, Title: "last", Created: created}
+	k := Keyset{Table: testPost{}, Properties: []string{"Created"}}
+	cursor, err := k.Cursor(last)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+
+	type keysetTest struct {
+		keyset   Keyset
+		dialect  Dialect
+		expected string
+		args     []any
+	}
+	tests := []keysetTest{
+		{
+			keyset:   k,
+			dialect:  PostgreSQL,
+			expected: "SELECT id FROM test_data WHERE author_id = $1 AND (created, id) > ($2, $3) ORDER BY created , id LIMIT $4;",
+			args:     []any{1, created, 7, int64(10)},
+		},
+		{
+			keyset:   Keyset{Table: testPost{}, Properties: []string{"Created"}, Descending: true, Expanded: true},
+			dialect:  PostgreSQL,
+			expected: "SELECT id FROM test_data WHERE author_id = $1 AND (created < $2 OR created = $2 AND id < $3) ORDER BY created DESC , id DESC LIMIT $4;",
+			args:     []any{1, created, 7, int64(10)},
+		},
+		{
+			keyset:   Keyset{Table: testPost{}, Properties: []string{"Created", "ID"}, Expanded: true},
+			dialect:  MySQL,
+			expected: "SELECT id FROM test_data WHERE author_id = ? AND (created > ? OR created = ? AND id > ?) ORDER BY created , id LIMIT ?;",
+			args:     []any{1, created, created, 7, int64(10)},
+		},
+		{
+			keyset:   k,
+			dialect:  SQLServer,
+			expected: "SELECT id FROM test_data WHERE author_id = @p1 AND (created > @p2 OR created = @p2 AND id > @p3) ORDER BY created , id OFFSET 0 ROWS FETCH NEXT @p4 ROWS ONLY;",
+			args:     []any{1, created, 7, int64(10)},
+		},
+	}
+	for pos, test := range tests {
+		q := Select[testPost](SelectProperties("ID")).Where(Comparison(testPost{}, "Author", "=", 1))
+		test.keyset.Page(q, cursor, 10).Flush(" ")
+		query, args, err := q.SQL(test.dialect)
+		if err != nil {
+			t.Errorf("Test %d: unexpected error: %+v", pos+1, err)
+			continue
+		}
+		if query != test.expected {
+			t.Errorf("Test %d: expected `%s`, got `%s`", pos+1, test.expected, query)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("Test %d: expected args %v, got %v", pos+1, test.args, args)
+		}
+	}
+
+	q := k.Page(Select[testPost](SelectProperties("ID")), "", 10).Flush(" ")
+	if query, _ := q.SQLiteString(); query != "SELECT id FROM test_data ORDER BY created , id LIMIT ?;" {
+		t.Errorf("Unexpected first page query `%s`", query)
+	}
+
+	// the comparison is chosen when the Query is rendered, not when the page is added
+	q = k.Page(Select[testPost](SelectProperties("ID")), cursor, 10).Flush(" ")
+	rendered := map[Dialect]string{
+		PostgreSQL: "SELECT id FROM test_data WHERE (created, id) > ($1, $2) ORDER BY created , id LIMIT $3;",
+		SQLServer:  "SELECT id FROM test_data WHERE (created > @p1 OR created = @p1 AND id > @p2) ORDER BY created , id OFFSET 0 ROWS FETCH NEXT @p3 ROWS ONLY;",
+	}
+	for d, expected := range rendered {
+		query, _, err := q.SQL(d)
+		if err != nil {
+			t.Errorf("Unexpected error: %+v", err)
+		}
+		if query != expected {
+			t.Errorf("Expected `%s`, got `%s`", expected, query)
+		}
+	}
+	if s := q.String(); s != "SELECT id FROM test_data WHERE (created, id) > ("+created.String()+", 7) ORDER BY created , id LIMIT 10" {
+		t.Errorf("Unexpected string `%s`", s)
+	}
+}
+
+func TestKeysetErrors(t *testing.T) {
+	t.Parallel()
+	k := Keyset{Table: testPost{}}
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "WzEsMl0", "WyJhIl0"} {
+		q := k.Page(Select[testPost](), cursor, 10).Flush(" ")
+		if _, _, err := q.SQL(PostgreSQL); err != ErrInvalidCursor {
+			t.Errorf("Cursor %q: expected %v, got %v", cursor, ErrInvalidCursor, err)
+		}
+	}
+	noKey := Keyset{Table: testType2{}}
+	if _, err := noKey.Cursor(testType2{}); err != ErrNoPrimaryKey {
+		t.Errorf("Expected %v, got %v", ErrNoPrimaryKey, err)
+	}
+	q := noKey.Page(New("SELECT * FROM more_tests"), "", 10).Flush(" ")
+	if _, _, err := q.SQL(PostgreSQL); err != ErrNoPrimaryKey {
+		t.Errorf("Expected %v, got %v", ErrNoPrimaryKey, err)
+	}
+}
+
+func TestKeysetSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_tags (post_id integer, tag varchar, weight integer, primary key (post_id, tag));")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tags []testTag
+	for i := 0; i < 25; i++ {
+		tags = append(tags, testTag{PostID: i % 4, Tag: string(rune('a' + i)), Weight: i % 3})
+	}
+	query, args, err := Insert(tags...).SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+	for _, expanded := range []bool{false, true} {
+		k := Keyset{Table: testTag{}, Properties: []string{"Weight"}, Descending: true, Expanded: expanded}
+		seen := map[string]bool{}
+		var cursor string
+		var pages int
+		for {
+			q := k.Page(Select[testTag](), cursor, 7).Flush(" ")
+			query, args, err := q.SQL(SQLite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := db.Query(query, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var last testTag
+			var count int
+			for rows.Next() {
+				if err := Unmarshal(rows, &last); err != nil {
+					t.Fatal(err)
+				}
+				if seen[last.Tag] {
+					t.Errorf("Row %+v was returned twice", last)
+				}
+				seen[last.Tag] = true
+				count++
+			}
+			rows.Close()
+			if count == 0 {
+				break
+			}
+			pages++
+			cursor, err = k.Cursor(last)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(seen) != len(tags) || pages != 4 {
+			t.Errorf("Expected %d rows in %d pages, got %d rows in %d pages", len(tags), 4, len(seen), pages)
+		}
+	}
+}
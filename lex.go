@@ -0,0 +1,286 @@
+package pan
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	// tokenText is SQL that should be passed through as-is. String literals,
+	// quoted identifiers, and comments are all text.
+	tokenText tokenKind = iota
+	// tokenPlaceholder is a `?` that should be filled with an argument.
+	tokenPlaceholder
+	// tokenDirective is a piece of SQL whose syntax depends on the Dialect
+	// the Query is rendered with. See directive.
+	tokenDirective
+	// tokenNamed is a `:name` placeholder. Named placeholders are only
+	// recognised by lexNamed, and are compiled into placeholders and
+	// directives before they're added to a Query.
+	tokenNamed
+	// tokenEscaped is an escaped question mark, written as `??`.
+	tokenEscaped
+)
+
+const (
+	directiveLimit  = "limit"
+	directiveOffset = "offset"
+	directiveIdent  = "ident"
+	// directiveRef is a placeholder for the same value as the placeholder
+	// its payload counts back to, so Dialects that can refer to an argument
+	// more than once don't need it repeated.
+	directiveRef = "ref"
+	// directiveInsert and directiveConflict are the start of an INSERT
+	// statement and the clause resolving its conflicts. Their payload is the
+	// conflict target and update columns; see Upsert.
+	directiveInsert   = "insert"
+	directiveConflict = "conflict"
+	// directiveReturning is the keyword that starts a RETURNING clause.
+	directiveReturning = "returning"
+	// directiveAlias is the keyword that precedes a table’s alias.
+	directiveAlias = "alias"
+	// directiveRecursive is the keywords that start a WITH clause whose
+	// common table expressions may refer to themselves.
+	directiveRecursive = "recursive"
+	// directiveLower and directiveILike surround the column on the left of a
+	// case-insensitive pattern match, so Dialects that can't use ILIKE can
+	// compare the lowercase column using LIKE instead.
+	directiveLower = "lower"
+	directiveILike = "ilike"
+	// directiveWhere marks where the WHERE clause built from the Conditions
+	// passed to Query.Where goes. It's replaced before the Query is rendered;
+	// see Query.materialize.
+	directiveWhere = "where"
+	// directiveKeyset is the Condition selecting the rows after a row in
+	// keyset pagination. Its payload is the operator, whether the comparison
+	// must be expanded, and the columns; see Keyset.Page. It consumes one
+	// argument per column.
+	directiveKeyset = "keyset"
+)
+
+// directive returns a marker that pan will replace with Dialect-specific SQL
+// when the Query is rendered. Directives are written as their name and
+// optional payload, separated by a colon, and wrapped in NUL bytes, which
+// can't appear in SQL outside of a literal.
+func directive(name string, payload ...string) string {
+	if len(payload) > 0 {
+		name += ":" + strings.Join(payload, ":")
+	}
+	return "\x00" + name + "\x00"
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// directive splits a tokenDirective into its name and payload.
+func (t token) directive() (name, payload string) {
+	name, payload, _ = strings.Cut(t.value, ":")
+	return name, payload
+}
+
+// args returns the number of arguments the token consumes.
+func (t token) args() int {
+	switch t.kind {
+	case tokenPlaceholder:
+		return 1
+	case tokenDirective:
+		switch name, payload := t.directive(); name {
+		case directiveLimit, directiveOffset, directiveRef:
+			return 1
+		case directiveKeyset:
+			_, _, columns := decodeKeyset(payload)
+			return len(columns)
+		}
+	}
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
+
+// quotedEnd returns the index just past the closing `quote` of the quoted
+// section starting at `start`, where `start` is the index of the opening
+// quote. A doubled quote is treated as an escaped quote, and if `backslash`
+// is true, backslashes escape the character following them. Unterminated
+// sections run to the end of `sql`.
+func quotedEnd(sql string, start int, quote byte, backslash bool) int {
+	for i := start + 1; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// blockCommentEnd returns the index just past the end of the block comment
+// starting at `start`. Block comments nest, as they do in PostgreSQL.
+func blockCommentEnd(sql string, start int) int {
+	depth := 0
+	for i := start; i < len(sql)-1; i++ {
+		if sql[i] == '/' && sql[i+1] == '*' {
+			depth++
+			i++
+		} else if sql[i] == '*' && sql[i+1] == '/' {
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(sql)
+}
+
+// dollarQuoteEnd returns the index just past the end of the dollar-quoted
+// string starting at `start`, or -1 if `start` doesn't begin a dollar quote.
+func dollarQuoteEnd(sql string, start int) int {
+	if start > 0 && isIdentChar(sql[start-1]) {
+		return -1
+	}
+	i := start + 1
+	if i < len(sql) && isIdentStart(sql[i]) {
+		for i < len(sql) && isIdentChar(sql[i]) && sql[i] != '$' {
+			i++
+		}
+	}
+	if i >= len(sql) || sql[i] != '$' {
+		return -1
+	}
+	tag := sql[start : i+1]
+	end := strings.Index(sql[i+1:], tag)
+	if end < 0 {
+		return len(sql)
+	}
+	return i + 1 + end + len(tag)
+}
+
+// literalEnd returns the index just past the string literal, quoted identifier,
+// dollar-quoted string, or comment starting at `start`, or -1 if none of them
+// start there. If `backslash` is true, backslashes escape the character after
+// them in single- and double-quoted strings, as they do in MySQL; otherwise they
+// only do in PostgreSQL’s escape strings, like E'\n'.
+func literalEnd(sql string, start int, backslash bool) int {
+	c := sql[start]
+	switch {
+	case c == '\'':
+		escapes := backslash || start > 0 && (sql[start-1] == 'E' || sql[start-1] == 'e') && (start < 2 || !isIdentChar(sql[start-2]))
+		return quotedEnd(sql, start, c, escapes)
+	case c == '"':
+		return quotedEnd(sql, start, c, backslash)
+	case c == '`':
+		return quotedEnd(sql, start, c, false)
+	case c == '-' && start+1 < len(sql) && sql[start+1] == '-':
+		end := strings.IndexByte(sql[start:], '\n')
+		if end < 0 {
+			return len(sql)
+		}
+		return start + end + 1
+	case c == '/' && start+1 < len(sql) && sql[start+1] == '*':
+		return blockCommentEnd(sql, start)
+	case c == '$':
+		return dollarQuoteEnd(sql, start)
+	}
+	return -1
+}
+
+// lex splits `sql` into text, placeholders, and directives. String literals, quoted
+// identifiers, dollar-quoted strings, and comments are treated as text, so
+// any `?` inside them isn't a placeholder. A `??` outside of them is an
+// escaped question mark. If `backslash` is true, backslashes escape the
+// character after them in strings; see literalEnd.
+func lex(sql string, backslash bool) []token {
+	return lexSQL(sql, false, backslash)
+}
+
+// lexNamed is like lex, but also recognises `:name` placeholders. A colon
+// following another colon, like in a PostgreSQL `::type` cast, or following
+// an identifier doesn't start a named placeholder.
+func lexNamed(sql string) []token {
+	return lexSQL(sql, true, false)
+}
+
+func lexSQL(sql string, named, backslash bool) []token {
+	var tokens []token
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, token{kind: tokenText, value: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		end := i + 1
+		switch {
+		case c == '?':
+			if i+1 < len(sql) && sql[i+1] == '?' {
+				flush()
+				tokens = append(tokens, token{kind: tokenEscaped, value: "??"})
+				i += 2
+				continue
+			}
+			flush()
+			tokens = append(tokens, token{kind: tokenPlaceholder, value: "?"})
+			i++
+			continue
+		case c == 0:
+			end = strings.IndexByte(sql[i+1:], 0)
+			if end >= 0 {
+				flush()
+				tokens = append(tokens, token{kind: tokenDirective, value: sql[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+			end = i + 1
+		case c == ':' && named && i+1 < len(sql) && isIdentStart(sql[i+1]) && (i == 0 || (sql[i-1] != ':' && !isIdentChar(sql[i-1]))):
+			for end = i + 1; end < len(sql) && isIdentChar(sql[end]) && sql[end] != '$'; end++ {
+			}
+			flush()
+			tokens = append(tokens, token{kind: tokenNamed, value: sql[i+1 : end]})
+			i = end
+			continue
+		default:
+			if e := literalEnd(sql, i, backslash); e > 0 {
+				end = e
+			}
+		}
+		text.WriteString(sql[i:end])
+		i = end
+	}
+	flush()
+	return tokens
+}
+
+// countPlaceholders returns the number of arguments `sql` expects, lexed as lex
+// does with `backslash`.
+func countPlaceholders(sql string, backslash bool) int {
+	var count int
+	for _, tok := range lex(sql, backslash) {
+		count += tok.args()
+	}
+	return count
+}
+
+// escapesBackslashes returns true if `d` fills the BackslashEscaper interface and
+// its strings escape characters using backslashes.
+func escapesBackslashes(d Dialect) bool {
+	escaper, ok := d.(BackslashEscaper)
+	return ok && escaper.EscapesBackslashes()
+}
@@ -1,8 +1,10 @@
 package pan
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -14,12 +16,33 @@ const (
 	FlagTicked
 	// FlagDoubleQuoted returns columns using double quotes to quote the column name, like "column".
 	FlagDoubleQuoted
+	// FlagQuoted returns columns quoted using the rules of the Dialect the Query is rendered with.
+	// Columns returned with this flag are only meaningful as part of a Query.
+	FlagQuoted
+	// FlagBracketed returns columns using square brackets to quote the column name, like [column].
+	FlagBracketed
 )
 
 var (
 	// ErrNeedsFlush is returned when a Query is used while it has expressions left in its buffer
 	// that haven’t been flushed using the Query’s Flush method.
 	ErrNeedsFlush = errors.New("Query has dangling buffer, its Flush method needs to be called")
+
+	// ErrNoPrimaryKey is returned when a Query needs to know the primary key of an
+	// SQLTableNamer, but none of its properties are tagged as part of the primary key.
+	ErrNoPrimaryKey = errors.New("SQLTableNamer has no properties tagged as a primary key")
+
+	// ErrNoValues is returned when a Query is built to insert values, but no values are
+	// passed.
+	ErrNoValues = errors.New("no values passed to insert")
+
+	// ErrNoQueries is returned when a Query is built to combine Queries, but no Queries
+	// are passed.
+	ErrNoQueries = errors.New("no queries passed to combine")
+
+	// ErrMixedWhere is returned when Where is called both with and without Conditions on
+	// the same Query, which would write the Conditions in the wrong place.
+	ErrMixedWhere = errors.New("Where called both with and without Conditions on the same Query")
 )
 
 // Query represents an SQL query that is being built. It can be used from its empty value,
@@ -32,12 +55,35 @@ var (
 // The Query type is not meant to be concurrency-safe; if you need to modify it from multiple
 // goroutines, you need to coordinate that access yourself.
 type Query struct {
-	sql           string
-	args          []any
-	expressions   []string
-	includesWhere bool
-	includesOrder bool
-	parent        *Query
+	sql            string
+	args           []any
+	expressions    []string
+	includesWhere  bool
+	includesOrder  bool
+	includesGroup  bool
+	includesHaving bool
+	parent         *Query
+
+	// predicates are the Conditions passed to Where, which are written in place
+	// of the where directive when the Query is materialized, with their
+	// arguments inserted at whereArg.
+	predicates []Condition
+	whereSlot  bool
+	whereArg   int
+
+	// ctes are the common table expressions added using With, written before
+	// the Query’s SQL when it’s materialized, with their arguments first.
+	ctes      []string
+	cteArgs   []any
+	recursive bool
+
+	// rendered is the Dialect the Query was last rendered with, whose order
+	// Args returns the arguments in.
+	rendered Dialect
+
+	// err holds the first error encountered while building the Query, and is
+	// returned when the Query is rendered.
+	err error
 }
 
 // ColumnList represents a set of columns.
@@ -62,10 +108,55 @@ func New(query string) *Query {
 }
 
 // Insert returns a Query instance containing SQL that will insert the passed `values` into
-// the database.
+// the database. If no values are passed, an ErrNoValues error will be returned when the
+// Query is rendered.
 func Insert[Type SQLTableNamer](values ...Type) *Query {
+	return insert("INSERT", values)
+}
+
+// InsertBatches returns Query instances containing SQL that will insert the passed `values`
+// into the database, splitting them between as many Queries as it takes to keep each Query
+// under `maxArgs` arguments. If `maxArgs` is 0 or less, the number of arguments `d` allows
+// is used instead, or, if `d` doesn’t fill the ArgLimiter interface, a single Query is
+// returned. If no values are passed, an ErrNoValues error is returned.
+//
+// `maxArgs` is meant for databases configured with a different limit than their Dialect
+// assumes, like SQLite builds that allow more than the 999 arguments older versions did.
+func InsertBatches[Type SQLTableNamer](d Dialect, maxArgs int, values ...Type) ([]*Query, error) {
+	if len(values) < 1 {
+		return nil, ErrNoValues
+	}
+	if limiter, ok := d.(ArgLimiter); ok && maxArgs < 1 {
+		maxArgs = limiter.MaxArgs()
+	}
+	perQuery := len(values)
+	if maxArgs > 0 {
+		if columns := len(Columns(values[0])); columns > 0 {
+			perQuery = maxArgs / columns
+		}
+		if perQuery < 1 {
+			perQuery = 1
+		}
+	}
+	queries := make([]*Query, 0, (len(values)+perQuery-1)/perQuery)
+	for start := 0; start < len(values); start += perQuery {
+		end := start + perQuery
+		if end > len(values) {
+			end = len(values)
+		}
+		queries = append(queries, Insert(values[start:end]...))
+	}
+	return queries, nil
+}
+
+func insert[Type SQLTableNamer](keyword string, values []Type) *Query {
+	if len(values) < 1 {
+		query := New("")
+		query.err = ErrNoValues
+		return query
+	}
 	columns := Columns(values[0])
-	query := New("INSERT INTO " + Table(values[0]) + " (" + columns.String() + ") VALUES")
+	query := New(keyword + " INTO " + Table(values[0]) + " (" + columns.String() + ") VALUES")
 
 	for _, v := range values {
 		columnValues := ColumnValues(v)
@@ -74,6 +165,213 @@ func Insert[Type SQLTableNamer](values ...Type) *Query {
 	return query.Flush(", ")
 }
 
+// Conflict describes how a Query built with Upsert resolves inserted rows that conflict
+// with rows already in the table.
+type Conflict struct {
+	// Target lists the properties whose unique constraint the conflict is on. If it’s
+	// empty, the properties tagged as the primary key are used. MySQL ignores Target,
+	// resolving conflicts on any unique constraint.
+	Target []string
+
+	// Update lists the properties whose columns should be set to the inserted values
+	// when there’s a conflict. If it’s empty, every column that isn’t part of Target or
+	// the primary key is updated; if there are no such columns, conflicting rows are
+	// left alone.
+	Update []string
+
+	// DoNothing leaves conflicting rows alone instead of updating them, using
+	// ON CONFLICT DO NOTHING or INSERT IGNORE.
+	DoNothing bool
+}
+
+// Upsert returns a Query instance containing SQL that will insert the passed `values` into
+// the database, resolving conflicts with existing rows as described by `conflict`. It
+// renders as INSERT ... ON CONFLICT for PostgreSQL and SQLite, and as INSERT ... ON
+// DUPLICATE KEY UPDATE or INSERT IGNORE for MySQL. Rendering it with a Dialect that
+// doesn’t fill the Upserter interface returns an ErrUnsupported error. Like Insert, if
+// no values are passed, an ErrNoValues error will be returned when the Query is rendered.
+//
+// If `conflict` has no Target, and no properties of the values are tagged as their primary
+// key, an ErrNoPrimaryKey error will be returned when the Query is rendered with a Dialect
+// that fills the ConflictTargeter interface, like PostgreSQL and SQLite, unless conflicting
+// rows are left alone. Every property in `conflict` must exactly match the name of a
+// property on the values, or Upsert will panic.
+func Upsert[Type SQLTableNamer](conflict Conflict, values ...Type) *Query {
+	if len(values) < 1 {
+		return insert("", values)
+	}
+	obj := values[0]
+	targetProps := conflict.Target
+	if len(targetProps) < 1 {
+		targetProps = primaryKeys(obj)
+	}
+	target := make([]string, 0, len(targetProps))
+	for _, property := range targetProps {
+		target = append(target, Column(obj, property))
+	}
+	var update []string
+	if !conflict.DoNothing && len(conflict.Update) > 0 {
+		for _, property := range conflict.Update {
+			update = append(update, Column(obj, property))
+		}
+	} else if !conflict.DoNothing {
+		// the primary key of the existing row is never overwritten, even if
+		// the conflict is on another constraint
+		keep := append([]string{}, target...)
+		for _, key := range primaryKeys(obj) {
+			keep = append(keep, Column(obj, key))
+		}
+		for _, column := range Columns(obj) {
+			var isKept bool
+			for _, k := range keep {
+				isKept = isKept || k == column
+			}
+			if !isKept {
+				update = append(update, column)
+			}
+		}
+	}
+	payload := encodeConflict(target, update)
+	query := insert(directive(directiveInsert, payload), values)
+	return query.Expression(directive(directiveConflict, payload)).Flush(" ")
+}
+
+// SelectOption configures the Query returned by Select.
+type SelectOption func(*selectOptions)
+
+type selectOptions struct {
+	flags      []Flag
+	distinct   bool
+	properties []string
+	joined     []SQLTableNamer
+	qualified  bool
+	alias      string
+}
+
+// SelectFlags returns a SelectOption that applies `flags` to the columns and table
+// named in the Query, the same way the flags passed to Columns are applied.
+func SelectFlags(flags ...Flag) SelectOption {
+	return func(o *selectOptions) {
+		o.flags = append(o.flags, flags...)
+	}
+}
+
+// Distinct returns a SelectOption that makes Select return a SELECT DISTINCT query.
+func Distinct() SelectOption {
+	return func(o *selectOptions) {
+		o.distinct = true
+	}
+}
+
+// SelectProperties returns a SelectOption that makes Select only select the columns
+// for `properties`, instead of every column. Each property must exactly match the name
+// of a property on the type being selected, or Select will panic.
+func SelectProperties(properties ...string) SelectOption {
+	return func(o *selectOptions) {
+		o.properties = append(o.properties, properties...)
+	}
+}
+
+// SelectJoined returns a SelectOption that makes Select also select the columns of each of
+// `tables`, for Queries that join them. Every column, including those of the type being
+// selected, is selected in its table.column format.
+func SelectJoined(tables ...SQLTableNamer) SelectOption {
+	return func(o *selectOptions) {
+		o.joined = append(o.joined, tables...)
+		o.qualified = true
+	}
+}
+
+// SelectAs returns a SelectOption that makes Select refer to the table being selected from
+// by `alias`, as if it had been passed to Aliased.
+func SelectAs(alias string) SelectOption {
+	return func(o *selectOptions) {
+		o.alias = alias
+	}
+}
+
+// Select returns a Query instance containing SQL that will select the columns of `Type`
+// from its table, in the form of "SELECT column, column FROM table". The Query can be
+// built on using Where, OrderBy, Limit, and friends.
+func Select[Type SQLTableNamer](options ...SelectOption) *Query {
+	var opts selectOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.qualified {
+		opts.flags = append(opts.flags, FlagFull)
+	}
+	var t SQLTableNamer = instance[Type]()
+	if opts.alias != "" {
+		t = Aliased(t, opts.alias)
+	}
+	columns := Columns(t, opts.flags...)
+	if len(opts.properties) > 0 {
+		columns = make(ColumnList, 0, len(opts.properties))
+		for _, property := range opts.properties {
+			columns = append(columns, Column(t, property, opts.flags...))
+		}
+	}
+	for _, table := range opts.joined {
+		columns = append(columns, Columns(table, opts.flags...)...)
+	}
+	sql := "SELECT "
+	if opts.distinct {
+		sql += "DISTINCT "
+	}
+	return New(sql + columns.String() + " FROM " + TableAs(t, opts.flags...))
+}
+
+// Update returns a Query instance containing SQL that will update the row `value` is
+// stored in, in the form of "UPDATE table SET column = ?, column = ?". Every column of
+// `value` is set, unless `properties` are passed, in which case only the columns for
+// those properties are set. Each property must exactly match the name of a property on
+// `value`, or Update will panic.
+//
+// The Query is already flushed, so a WHERE clause can be added to it straight away.
+func Update[Type SQLTableNamer](value Type, properties ...string) *Query {
+	query := New("UPDATE " + Table(value) + " SET")
+	if len(properties) < 1 {
+		columns := Columns(value)
+		values := ColumnValues(value)
+		for pos, column := range columns {
+			query.Expression(column+" = ?", values[pos])
+		}
+		return query.Flush(", ")
+	}
+	for _, property := range properties {
+		query.Assign(value, property, propertyValue(value, property))
+	}
+	return query.Flush(", ")
+}
+
+// Delete returns a Query instance containing SQL that will delete rows from the table
+// for `Type`, in the form of "DELETE FROM table". Without a WHERE clause, the Query will
+// delete every row in the table.
+func Delete[Type SQLTableNamer]() *Query {
+	return New("DELETE FROM " + Table(instance[Type]()))
+}
+
+// DeleteByPK returns a Query instance containing SQL that will delete the row `value`
+// is stored in, in the form of "DELETE FROM table WHERE pk = ?". The primary key is
+// made up of the properties of `value` whose sql_column tag has the `pk` option, like
+// `sql_column:"id,pk"`; if the primary key is made up of more than one property, they
+// must all match. If no properties have the option, an ErrNoPrimaryKey error will be
+// returned when the Query is rendered.
+func DeleteByPK[Type SQLTableNamer](value Type) *Query {
+	query := New("DELETE FROM " + Table(value))
+	keys := primaryKeys(value)
+	if len(keys) < 1 {
+		query.err = ErrNoPrimaryKey
+		return query
+	}
+	conds := make([]Condition, 0, len(keys))
+	for _, key := range keys {
+		conds = append(conds, Comparison(value, key, "=", propertyValue(value, key)))
+	}
+	return query.Where(conds...)
+}
+
 // ErrWrongNumberArgs is returned when you’ve generated a Query with a certain number of
 // placeholders, but supplied a different number of arguments. The NumExpected property
 // holds the number of placeholders in the Query, and the NumFound property holds the
@@ -88,51 +386,59 @@ func (e ErrWrongNumberArgs) Error() string {
 	return fmt.Sprintf("Expected %d arguments, got %d.", e.NumExpected, e.NumFound)
 }
 
-func (q *Query) checkCounts() error {
-	placeholders := strings.Count(q.sql, "?")
-	args := len(q.args)
+// ErrMissingNamedArg is returned when an expression uses a named parameter that no value
+// was supplied for. The Name property holds the name of the parameter.
+type ErrMissingNamedArg struct {
+	Name string
+}
+
+// Error fills the error interface.
+func (e ErrMissingNamedArg) Error() string {
+	return fmt.Sprintf("No value supplied for named parameter %q.", e.Name)
+}
+
+// ErrUnusedNamedArg is returned when a value is supplied for a named parameter that the
+// expression doesn’t use. The Name property holds the name of the parameter.
+type ErrUnusedNamedArg struct {
+	Name string
+}
+
+// Error fills the error interface.
+func (e ErrUnusedNamedArg) Error() string {
+	return fmt.Sprintf("Value supplied for named parameter %q, which isn't used.", e.Name)
+}
+
+// checkCounts returns an error if the number of placeholders in the Query doesn’t match
+// the number of arguments, when it’s rendered with `d`. If `d` is nil, because the Dialect
+// isn’t known yet, the numbers only need to match using one of the ways strings can be
+// escaped.
+func (q *Query) checkCounts(d Dialect) error {
+	if q.err != nil {
+		return q.err
+	}
+	sql, allArgs := q.materialize()
+	positional, _ := splitArgs(allArgs)
+	args := len(positional)
+	placeholders := countPlaceholders(sql, d != nil && escapesBackslashes(d))
+	if d == nil && placeholders != args && countPlaceholders(sql, true) == args {
+		return nil
+	}
 	if placeholders != args {
 		return ErrWrongNumberArgs{NumExpected: placeholders, NumFound: args}
 	}
 	return nil
 }
 
-// String returns a version of your Query with all the arguments in the place of their
-// placeholders. It does not do any sanitization, and is vulnerable to SQL injection.
-// It is meant as a debugging aid, not to be executed. The string will almost certainly
-// not be valid SQL.
-func (q *Query) String() string {
-	var argPos int
-	var res string
-	toCheck := q.sql
-	for i := strings.Index(toCheck, "?"); i >= 0; argPos++ {
-		var arg any
-		arg = "!{MISSING}"
-		if len(q.args) > argPos {
-			arg = q.args[argPos]
-		}
-		res += toCheck[:i]
-		res += fmt.Sprintf("%v", arg)
-		toCheck = toCheck[i+1:]
-		i = strings.Index(toCheck, "?")
-	}
-	res += toCheck
-	return res
-}
-
 // MySQLString returns a SQL string that can be passed to MySQL to execute your query.
 // If the number of placeholders do not match the number of arguments provided to your
 // Query, an ErrWrongNumberArgs error will be returned. If there are still expressions
 // left in the buffer (meaning the Flush method wasn't called) an ErrNeedsFlush error
 // will be returned.
+//
+// MySQLString is shorthand for calling SQL with the MySQL Dialect.
 func (q *Query) MySQLString() (string, error) {
-	if len(q.expressions) != 0 {
-		return "", ErrNeedsFlush
-	}
-	if err := q.checkCounts(); err != nil {
-		return "", err
-	}
-	return q.sql + ";", nil
+	sql, _, err := q.SQL(MySQL)
+	return sql, err
 }
 
 // SQLiteString returns a SQL string that can be passed to SQLite to execute
@@ -140,14 +446,11 @@ func (q *Query) MySQLString() (string, error) {
 // arguments provided to your Query, an ErrWrongNumberArgs error will be
 // returned. If there are still expressions left in the buffer (meaning the
 // Flush method wasn't called) an ErrNeedsFlush error will be returned.
+//
+// SQLiteString is shorthand for calling SQL with the SQLite Dialect.
 func (q *Query) SQLiteString() (string, error) {
-	if len(q.expressions) != 0 {
-		return "", ErrNeedsFlush
-	}
-	if err := q.checkCounts(); err != nil {
-		return "", err
-	}
-	return q.sql + ";", nil
+	sql, _, err := q.SQL(SQLite)
+	return sql, err
 }
 
 // PostgreSQLString returns an SQL string that can be passed to PostgreSQL to execute
@@ -155,24 +458,47 @@ func (q *Query) SQLiteString() (string, error) {
 // provided to your Query, an ErrWrongNumberArgs error will be returned. If there are
 // still expressions left in the buffer (meaning the Flush method wasn't called) an
 // ErrNeedsFlush error will be returned.
+//
+// PostgreSQLString is shorthand for calling SQL with the PostgreSQL Dialect.
 func (q *Query) PostgreSQLString() (string, error) {
+	sql, _, err := q.SQL(PostgreSQL)
+	return sql, err
+}
+
+// SQLServerString returns an SQL string that can be passed to Microsoft SQL Server to
+// execute your query. If the number of placeholders do not match the number of arguments
+// provided to your Query, an ErrWrongNumberArgs error will be returned. If there are
+// still expressions left in the buffer (meaning the Flush method wasn't called) an
+// ErrNeedsFlush error will be returned.
+//
+// SQLServerString is shorthand for calling SQL with the SQLServer Dialect. SQL Server binds
+// the offset before the limit, so after SQLServerString is called, Args returns the
+// arguments in that order.
+func (q *Query) SQLServerString() (string, error) {
+	sql, _, err := q.SQL(SQLServer)
+	return sql, err
+}
+
+// OracleString returns an SQL string that can be passed to Oracle to execute your query.
+// If the number of placeholders do not match the number of arguments provided to your
+// Query, an ErrWrongNumberArgs error will be returned. If there are still expressions
+// left in the buffer (meaning the Flush method wasn't called) an ErrNeedsFlush error
+// will be returned.
+//
+// OracleString is shorthand for calling SQL with the Oracle Dialect. Oracle binds the offset
+// before the limit, so after OracleString is called, Args returns the arguments in that
+// order.
+func (q *Query) OracleString() (string, error) {
+	sql, _, err := q.SQL(Oracle)
+	return sql, err
+}
+
+// subquery returns an error if the Query can’t be used as a subquery of another Query.
+func (q *Query) subquery() error {
 	if len(q.expressions) != 0 {
-		return "", ErrNeedsFlush
-	}
-	if err := q.checkCounts(); err != nil {
-		return "", err
-	}
-	count := 1
-	var res string
-	toCheck := q.sql
-	for i := strings.Index(toCheck, "?"); i >= 0; count++ {
-		res += toCheck[:i]
-		res += "$" + strconv.Itoa(count)
-		toCheck = toCheck[i+1:]
-		i = strings.Index(toCheck, "?")
+		return ErrNeedsFlush
 	}
-	res += toCheck
-	return res + ";", nil
+	return q.checkCounts(nil)
 }
 
 // ComplexExpression starts a Query with a new buffer, so it can be flushed
@@ -202,10 +528,11 @@ func (q *Query) AppendToParent() *Query {
 	if len(q.expressions) > 0 {
 		panic(ErrNeedsFlush)
 	}
-	if err := q.checkCounts(); err != nil {
+	if err := q.checkCounts(nil); err != nil {
 		panic(err)
 	}
-	return q.parent.Expression(q.sql, q.args...)
+	sql, args := q.materialize()
+	return q.parent.Expression(sql, args...)
 }
 
 // Flush flushes the expressions in the Query’s buffer, adding them to the SQL string
@@ -224,49 +551,487 @@ func (q *Query) Flush(join string) *Query {
 }
 
 // Expression adds a raw string and optional values to the Query’s buffer.
+//
+// Placeholders for `values` are written as `?`. A `?` inside a string literal, a quoted
+// identifier, or a comment is not a placeholder. To use a literal question mark anywhere
+// else, like PostgreSQL’s `?`, `?|`, and `?&` JSONB operators, write it as `??`. Rendering a
+// Query that uses `??` with a Dialect whose placeholders are `?`, like MySQL and SQLite,
+// returns an ErrUnsupported error, as the question mark would be read as a placeholder.
+//
+// Placeholders can also be named, like `:name`, and filled using a NamedArgs or sql.Named
+// values passed in `values`. A name can be used more than once in `key`. If a name in `key`
+// has no value, or a name in a NamedArgs isn’t used in `key`, an ErrMissingNamedArg or
+// ErrUnusedNamedArg error will be returned when the Query is rendered. Named placeholders
+// are only recognised when a NamedArgs or sql.NamedArg is passed.
+//
+// A sql.NamedArg whose name isn’t used by a placeholder in `key` is passed after the other
+// arguments, for drivers that bind them by name, like `@name` in SQL Server. Values of any
+// other type, including maps, are passed to the database as they are.
+//
+// A *Query passed as a value is inlined as a subquery, wrapped in parentheses, with its
+// arguments in place of the placeholder. If the *Query hasn’t been flushed, an
+// ErrNeedsFlush error will be returned when the outer Query is rendered.
 func (q *Query) Expression(key string, values ...any) *Query {
+	key, values, err := compileExpression(key, values)
+	if err != nil && q.err == nil {
+		q.err = err
+	}
 	q.expressions = append(q.expressions, key)
 	q.args = append(q.args, values...)
 	return q
 }
 
+// NamedArgs holds the values for the named placeholders of an expression, like `:name`,
+// keyed by their names. See Query.Expression.
+type NamedArgs map[string]any
+
+// compileExpression resolves the named placeholders in `key` using the named values in
+// `values`, and inlines any *Query values as subqueries, returning an expression that
+// only uses positional placeholders, and the arguments for it. A name used more than
+// once is written as a reference to its first placeholder, so Dialects that can reuse
+// placeholders don’t need the argument twice.
+func compileExpression(key string, values []any) (string, []any, error) {
+	var named map[string]any
+	var positional, byName []any
+	var subqueries bool
+	for _, value := range values {
+		switch v := value.(type) {
+		case NamedArgs:
+			if named == nil {
+				named = map[string]any{}
+			}
+			for name, val := range v {
+				named[name] = val
+				_, ok := val.(*Query)
+				subqueries = subqueries || ok
+			}
+		case sql.NamedArg:
+			byName = append(byName, v)
+		case *Query:
+			subqueries = true
+			positional = append(positional, value)
+		default:
+			positional = append(positional, value)
+		}
+	}
+	if len(byName) > 0 {
+		// sql.NamedArg values fill the placeholders using their names, and
+		// are passed on to the driver if there aren't any
+		inKey := map[string]bool{}
+		for _, tok := range lexSQL(key, true, false) {
+			if tok.kind == tokenNamed {
+				inKey[tok.value] = true
+			}
+		}
+		var passed []any
+		for _, value := range byName {
+			arg := value.(sql.NamedArg)
+			if !inKey[arg.Name] {
+				passed = append(passed, arg)
+				continue
+			}
+			if named == nil {
+				named = map[string]any{}
+			}
+			if _, ok := named[arg.Name]; !ok {
+				named[arg.Name] = arg.Value
+				_, ok := arg.Value.(*Query)
+				subqueries = subqueries || ok
+			}
+		}
+		byName = passed
+	}
+	if named == nil && !subqueries {
+		return key, values, nil
+	}
+	var res strings.Builder
+	var args []any
+	first := map[string]int{}
+	used := map[string]bool{}
+	bind := func(value any, name string) error {
+		if sub, ok := value.(*Query); ok {
+			if err := sub.subquery(); err != nil {
+				return err
+			}
+			sql, subArgs := sub.materialize()
+			res.WriteString("(" + sql + ")")
+			args = append(args, subArgs...)
+			return nil
+		}
+		if pos, ok := first[name]; ok {
+			res.WriteString(directive(directiveRef, strconv.Itoa(len(args)-pos)))
+		} else {
+			if name != "" {
+				first[name] = len(args)
+			}
+			res.WriteString("?")
+		}
+		args = append(args, value)
+		return nil
+	}
+	for _, tok := range lexSQL(key, named != nil, false) {
+		switch tok.kind {
+		case tokenNamed:
+			value, ok := named[tok.value]
+			if !ok {
+				return key, values, ErrMissingNamedArg{Name: tok.value}
+			}
+			used[tok.value] = true
+			if err := bind(value, tok.value); err != nil {
+				return key, values, err
+			}
+		case tokenPlaceholder:
+			if len(positional) < 1 {
+				res.WriteString("?")
+				continue
+			}
+			if err := bind(positional[0], ""); err != nil {
+				return key, values, err
+			}
+			positional = positional[1:]
+		case tokenDirective:
+			res.WriteString(directive(tok.value))
+		default:
+			res.WriteString(tok.value)
+		}
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !used[name] {
+			return key, values, ErrUnusedNamedArg{Name: name}
+		}
+	}
+	return res.String(), append(append(args, positional...), byName...), nil
+}
+
+// splitArgs returns the arguments that are bound to placeholders in `args`, and the
+// sql.NamedArg values, which drivers bind by name.
+func splitArgs(args []any) (positional, byName []any) {
+	for _, arg := range args {
+		if _, ok := arg.(sql.NamedArg); ok {
+			byName = append(byName, arg)
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	return positional, byName
+}
+
 // Where adds a WHERE keyword to the Query’s buffer, then calls Flush on the Query,
 // using a space as the join parameter.
 //
-// Where can only be called once per Query; calling it multiple times on the same Query
-// will be no-ops after the first.
-func (q *Query) Where() *Query {
-	if q.includesWhere {
+// If `conds` are passed, Where can be called as many times as needed: the first call
+// marks where the WHERE clause goes, and every call adds `conds` to it, even if other
+// expressions have been added to the Query since. When the Query is used, the WHERE
+// clause is written with all the Conditions joined by AND, or left out entirely if
+// every Condition passed to Where was empty:
+//
+//	q := pan.Select[Post]().Where(pan.Comparison(p, "Author", "=", author))
+//	q.OrderBy(pan.Column(p, "Created"))
+//	q.Where(pan.Comparison(p, "Draft", "=", false))
+//	q.Flush(" ")
+//	// SELECT ... FROM posts WHERE author = ? AND draft = ? ORDER BY created
+//
+// Without `conds`, Where adds the WHERE keyword once per Query; calling it multiple
+// times on the same Query will be no-ops after the first. Calling Where both with and
+// without `conds` on the same Query sets an ErrMixedWhere error, which is returned when
+// the Query is rendered.
+func (q *Query) Where(conds ...Condition) *Query {
+	if q.includesWhere && q.whereSlot == (len(conds) < 1) {
+		if q.err == nil {
+			q.err = ErrMixedWhere
+		}
 		return q
 	}
-	q.Expression("WHERE")
-	q.Flush(" ")
-	q.includesWhere = true
+	if len(conds) < 1 {
+		return q.clause("WHERE", &q.includesWhere, conds)
+	}
+	if !q.whereSlot {
+		q.Expression(directive(directiveWhere))
+		q.Flush(" ")
+		q.includesWhere = true
+		q.whereSlot = true
+		q.whereArg = len(q.args)
+	}
+	for _, cond := range conds {
+		if cond.err != nil && q.err == nil {
+			q.err = cond.err
+		}
+	}
+	q.predicates = append(q.predicates, conds...)
 	return q
 }
 
+// materialize returns the Query’s SQL and arguments, with the Conditions passed to
+// Where written in place of the where directive, and the common table expressions
+// added using With written before them.
+func (q *Query) materialize() (string, []any) {
+	sql, args := q.materializeWhere()
+	if len(q.ctes) < 1 {
+		return sql, args
+	}
+	keyword := "WITH"
+	if q.recursive {
+		keyword = directive(directiveRecursive)
+	}
+	sql = keyword + " " + strings.Join(q.ctes, ", ") + " " + sql
+	return sql, append(append([]any{}, q.cteArgs...), args...)
+}
+
+func (q *Query) materializeWhere() (string, []any) {
+	if !q.whereSlot {
+		return q.sql, q.args
+	}
+	slot := directive(directiveWhere)
+	cond := And(q.predicates...)
+	if cond.sql == "" {
+		// don't leave the spaces around the clause doubled up
+		for _, s := range []string{" " + slot, slot + " ", slot} {
+			if strings.Contains(q.sql, s) {
+				return strings.Replace(q.sql, s, "", 1), q.args
+			}
+		}
+		return q.sql, q.args
+	}
+	pos := q.whereArg
+	if pos > len(q.args) {
+		pos = len(q.args)
+	}
+	args := make([]any, 0, len(q.args)+len(cond.args))
+	args = append(args, q.args[:pos]...)
+	args = append(args, cond.args...)
+	args = append(args, q.args[pos:]...)
+	return strings.Replace(q.sql, slot, "WHERE "+cond.sql, 1), args
+}
+
+// With adds a common table expression named `name` to the Query, which the Query’s SQL
+// can refer to like a table. Common table expressions are written before the Query’s
+// SQL, in the form of "WITH name (column, column) AS (cte)", in the order they were
+// added, and their arguments come before the Query’s. `columns` can be omitted to use
+// the names of the columns `cte` selects.
+//
+// `cte` is written as it is when With is called; if it hasn’t been flushed, an
+// ErrNeedsFlush error will be returned when the Query is rendered.
+func (q *Query) With(name string, cte *Query, columns ...string) *Query {
+	return q.with(name, cte, columns, false)
+}
+
+// WithRecursive is like With, but `cte` may refer to itself by `name`, usually in the
+// second half of a UNION ALL. If any common table expression is added using
+// WithRecursive, the WITH clause is written as WITH RECURSIVE, or however the Dialect
+// the Query is rendered with starts a recursive WITH clause.
+func (q *Query) WithRecursive(name string, cte *Query, columns ...string) *Query {
+	return q.with(name, cte, columns, true)
+}
+
+func (q *Query) with(name string, cte *Query, columns []string, recursive bool) *Query {
+	if err := cte.subquery(); err != nil {
+		if q.err == nil {
+			q.err = err
+		}
+		return q
+	}
+	sql, args := cte.materialize()
+	if len(columns) > 0 {
+		name += " (" + strings.Join(columns, ", ") + ")"
+	}
+	q.ctes = append(q.ctes, name+" AS ("+sql+")")
+	q.cteArgs = append(q.cteArgs, args...)
+	q.recursive = q.recursive || recursive
+	return q
+}
+
+// Union returns a Query instance combining the rows returned by `queries`, without
+// duplicates, in the form of "query UNION query". The arguments of `queries` are merged
+// in order. OrderBy, Limit, and Offset can be used on the returned Query to order and
+// limit the combined rows.
+//
+// `queries` are written as they are when Union is called, without parentheses, so they
+// can’t be ordered or limited themselves. If any of them haven’t been flushed, an
+// ErrNeedsFlush error will be returned when the returned Query is rendered. If no queries
+// are passed, an ErrNoQueries error will be returned.
+func Union(queries ...*Query) *Query {
+	return combineQueries("UNION", queries)
+}
+
+// UnionAll is like Union, but keeps duplicate rows, in the form of "query UNION ALL query".
+func UnionAll(queries ...*Query) *Query {
+	return combineQueries("UNION ALL", queries)
+}
+
+// Intersect is like Union, but only returns the rows returned by every one of `queries`,
+// in the form of "query INTERSECT query".
+func Intersect(queries ...*Query) *Query {
+	return combineQueries("INTERSECT", queries)
+}
+
+// Except is like Union, but returns the rows of the first of `queries` that aren’t
+// returned by any of the others, in the form of "query EXCEPT query".
+func Except(queries ...*Query) *Query {
+	return combineQueries("EXCEPT", queries)
+}
+
+func combineQueries(operator string, queries []*Query) *Query {
+	res := New("")
+	if len(queries) < 1 {
+		res.err = ErrNoQueries
+		return res
+	}
+	sql := make([]string, 0, len(queries))
+	for _, query := range queries {
+		if err := query.subquery(); err != nil {
+			res.err = err
+			return res
+		}
+		querySQL, args := query.materialize()
+		sql = append(sql, querySQL)
+		res.args = append(res.args, args...)
+	}
+	res.sql = strings.Join(sql, " "+operator+" ")
+	return res
+}
+
+// clause adds `keyword` to the Query’s buffer, unless `included` is already true, followed
+// by `conds`, joined by AND, then calls Flush on the Query, using a space as the join
+// parameter. If `keyword` was already included, `conds` are preceded by AND instead.
+func (q *Query) clause(keyword string, included *bool, conds []Condition) *Query {
+	cond := And(conds...)
+	if len(conds) > 0 && cond.sql == "" {
+		if cond.err != nil && q.err == nil {
+			q.err = cond.err
+		}
+		return q
+	}
+	if !*included {
+		q.Expression(keyword)
+		q.Flush(" ")
+		*included = true
+	} else if cond.sql != "" {
+		q.Expression("AND")
+	}
+	if cond.sql == "" {
+		return q
+	}
+	cond.sql = cond.operand(precedenceAnd)
+	return q.condition(cond).Flush(" ")
+}
+
 // Comparison adds a comparison expression to the Query’s buffer. A comparison takes the
 // form of `column operator ?`, with `value` added as an argument to the Query. Column is
 // determined by finding the column name for the passed property on the passed SQLTableNamer.
-// The passed property must be a string that matches, identically, the property name; if it
-// does not, it will panic.
-func (q *Query) Comparison(obj SQLTableNamer, property, operator string, value any) *Query {
-	return q.Expression(Column(obj, property)+" "+operator+" ?", value)
+// The passed property must be a string that matches, identically, the property name, or a
+// pointer to the field, like Col takes; if it does not, it will panic. If `value` is a
+// *Query, it is used as a subquery.
+func (q *Query) Comparison(obj SQLTableNamer, property any, operator string, value any) *Query {
+	return q.condition(Comparison(obj, property, operator, value))
 }
 
 // In adds an expression to the Query’s buffer in the form of "column IN (value, value, value)".
 // `values` are the variables to match against, and `obj` and `property` are used to determine
-// the column. `property` must exactly match the name of a property on `obj`, or the call will
-// panic.
-func (q *Query) In(obj SQLTableNamer, property string, values ...any) *Query {
-	return q.Expression(Column(obj, property)+" IN("+Placeholders(len(values))+")", values...)
+// the column. `property` must exactly match the name of a property on `obj`, or be a pointer to
+// the field, like Col takes, or the call will panic. If the only value is a *Query, the
+// expression takes the form "column IN (subquery)".
+//
+// Slices and arrays in `values` are expanded, so each of their elements is matched against,
+// except for []byte and values that implement driver.Valuer. If there are no values to match
+// against, the expression is always false, and takes the form "1=0".
+func (q *Query) In(obj SQLTableNamer, property any, values ...any) *Query {
+	return q.condition(In(obj, property, values...))
+}
+
+// NotIn adds an expression to the Query’s buffer in the form of "column NOT IN (value, value,
+// value)". It takes the same arguments as In, but if there are no values to match against,
+// the expression is always true, and takes the form "1=1".
+func (q *Query) NotIn(obj SQLTableNamer, property any, values ...any) *Query {
+	return q.condition(NotIn(obj, property, values...))
+}
+
+// Between adds an expression to the Query’s buffer in the form of "column BETWEEN ? AND ?",
+// and adds `low` and `high` to the arguments for this query. `obj` and `property` are used to
+// determine the column. `property` must exactly match the name of a property on `obj`, or be
+// a pointer to the field, like Col takes, or the call will panic.
+func (q *Query) Between(obj SQLTableNamer, property any, low, high any) *Query {
+	return q.condition(Between(obj, property, low, high))
+}
+
+// IsNull adds an expression to the Query’s buffer in the form of "column IS NULL". `obj` and
+// `property` are used to determine the column, like Between uses them.
+func (q *Query) IsNull(obj SQLTableNamer, property any) *Query {
+	return q.condition(IsNull(obj, property))
+}
+
+// IsNotNull adds an expression to the Query’s buffer in the form of "column IS NOT NULL".
+// `obj` and `property` are used to determine the column, like Between uses them.
+func (q *Query) IsNotNull(obj SQLTableNamer, property any) *Query {
+	return q.condition(IsNotNull(obj, property))
+}
+
+// Like adds an expression to the Query’s buffer in the form of "column LIKE ? ESCAPE '!'",
+// matching the column against `value` in the way `mode` describes. See the Like function for
+// how `value` is escaped.
+func (q *Query) Like(obj SQLTableNamer, property any, value string, mode LikeMode) *Query {
+	return q.condition(Like(obj, property, value, mode))
+}
+
+// ILike is like Like, but ignores case. See the ILike function for the form it takes.
+func (q *Query) ILike(obj SQLTableNamer, property any, value string, mode LikeMode) *Query {
+	return q.condition(ILike(obj, property, value, mode))
 }
 
 // Assign adds an expression to the Query’s buffer in the form of "column = ?", and adds `value`
 // to the arguments for this query. `obj` and `property` are used to determine the column.
-// `property` must exactly match the name of a property on `obj`, or the call will panic.
-func (q *Query) Assign(obj SQLTableNamer, property string, value any) *Query {
-	return q.Expression(Column(obj, property)+" = ?", value)
+// `property` must exactly match the name of a property on `obj`, or be a pointer to the field,
+// like Col takes, or the call will panic.
+func (q *Query) Assign(obj SQLTableNamer, property any, value any) *Query {
+	return q.Expression(column(obj, property)+" = ?", value)
+}
+
+// JoinKind is a type of SQL join. See the constants defined in this package for valid values.
+type JoinKind string
+
+const (
+	// InnerJoin only includes rows that match in both tables.
+	InnerJoin JoinKind = "INNER JOIN"
+	// LeftJoin includes every row of the left table, even if it has no match in the right.
+	LeftJoin JoinKind = "LEFT JOIN"
+	// RightJoin includes every row of the right table, even if it has no match in the left.
+	RightJoin JoinKind = "RIGHT JOIN"
+	// FullJoin includes every row of both tables, whether they match or not.
+	FullJoin JoinKind = "FULL JOIN"
+)
+
+// Join adds an expression to the Query’s buffer in the form of "INNER JOIN right ON
+// left.column = right.column", joining the table of `right` on the columns for `leftProp`
+// and `rightProp`. The columns are in their table.column format, and `flags` are applied to
+// them and the table name. `leftProp` and `rightProp` must exactly match the names of
+// properties on `left` and `right`, or be pointers to the fields, like Col takes, or the
+// call will panic.
+func (q *Query) Join(kind JoinKind, left SQLTableNamer, leftProp any, right SQLTableNamer, rightProp any, flags ...Flag) *Query {
+	flags = append(flags, FlagFull)
+	return q.Expression(string(kind) + " " + TableAs(right, flags...) + " ON " + column(left, leftProp, flags...) + " = " + column(right, rightProp, flags...))
+}
+
+// Returning adds an expression to the Query’s buffer in the form of "RETURNING column, column",
+// so an INSERT, UPDATE, or DELETE statement returns the rows it affected. The columns are those
+// of `obj`, unless `properties` are passed, in which case only the columns for those properties
+// are returned. Each property must exactly match the name of a property on `obj`, or be a
+// pointer to one of its fields, like Col takes, or the call will panic.
+//
+// The returned rows can be read using Unmarshal. Rendering the Query with a Dialect that doesn’t
+// fill the Returner interface returns an ErrUnsupported error.
+func (q *Query) Returning(obj SQLTableNamer, properties ...any) *Query {
+	columns := Columns(obj)
+	if len(properties) > 0 {
+		columns = make(ColumnList, 0, len(properties))
+		for _, property := range properties {
+			columns = append(columns, column(obj, property))
+		}
+	}
+	return q.Expression(directive(directiveReturning) + " " + columns.String())
 }
 
 func (q *Query) orderBy(orderClause, dir string) *Query {
@@ -289,23 +1054,73 @@ func (q *Query) OrderByDesc(column string) *Query {
 	return q.orderBy(column, " DESC")
 }
 
+func (q *Query) groupBy(columns []string) *Query {
+	if len(columns) < 1 {
+		return q
+	}
+	exp := ", "
+	if !q.includesGroup {
+		exp = "GROUP BY "
+		q.includesGroup = true
+	}
+	return q.Expression(exp + strings.Join(columns, ", "))
+}
+
+// GroupBy adds an expression to the Query’s buffer in the form of "GROUP BY column, column".
+// Calling it again adds more columns to the same GROUP BY clause.
+func (q *Query) GroupBy(columns ...string) *Query {
+	return q.groupBy(columns)
+}
+
+// GroupByProperties adds an expression to the Query’s buffer in the form of "GROUP BY column,
+// column", using the columns for `properties` on `obj`. Each property must exactly match the
+// name of a property on `obj`, or be a pointer to the field, like Col takes, or the call will
+// panic. Calling it again adds more columns to the same GROUP BY clause.
+func (q *Query) GroupByProperties(obj SQLTableNamer, properties ...any) *Query {
+	columns := make([]string, 0, len(properties))
+	for _, property := range properties {
+		columns = append(columns, column(obj, property))
+	}
+	return q.groupBy(columns)
+}
+
+// Having adds a HAVING keyword to the Query’s buffer, followed by `conds`, joined by AND,
+// then calls Flush on the Query, using a space as the join parameter. It follows the same
+// rules as Where: the HAVING keyword is only added once, and `conds` passed to later calls
+// are preceded by AND.
+func (q *Query) Having(conds ...Condition) *Query {
+	return q.clause("HAVING", &q.includesHaving, conds)
+}
+
 // Limit adds an expression to the Query’s buffer in the form of "LIMIT ?", and adds `limit` as
-// an argument to the Query.
+// an argument to the Query. The clause is written using the syntax of the Dialect the Query
+// is rendered with; a Limit and Offset next to each other are rendered as a single clause.
 func (q *Query) Limit(limit int64) *Query {
-	return q.Expression("LIMIT ?", limit)
+	return q.Expression(directive(directiveLimit), limit)
 }
 
 // Offset adds an expression to the Query’s buffer in the form of "OFFSET ?", and adds `offset`
-// as an argument to the Query.
+// as an argument to the Query. The clause is written using the syntax of the Dialect the Query
+// is rendered with; a Limit and Offset next to each other are rendered as a single clause.
 func (q *Query) Offset(offset int64) *Query {
-	return q.Expression("OFFSET ?", offset)
+	return q.Expression(directive(directiveOffset), offset)
 }
 
 // Args returns a slice of the arguments attached to the Query, which should be used when executing
-// your SQL to fill the placeholders.
+// your SQL to fill the placeholders. Once the Query has been rendered using SQL, or a shorthand
+// like PostgreSQLString, the arguments are in the order the Dialect it was last rendered with
+// expects them, which may not be the order they were added in: some Dialects write OFFSET before
+// LIMIT, and some refer to an argument used more than once by a single placeholder. Before
+// then, or if rendering fails, they’re in the order they were added to the Query.
 //
-// Note that Args returns its internal slice; you should copy the returned slice over before modifying
-// it.
+// Note that Args may return its internal slice; you should copy the returned slice over before
+// modifying it.
 func (q *Query) Args() []any {
-	return q.args
+	if q.rendered != nil {
+		if _, args, err := q.SQL(q.rendered); err == nil {
+			return args
+		}
+	}
+	_, args := q.materialize()
+	return args
 }
@@ -1,7 +1,9 @@
 package pan
 
 import (
+	"database/sql"
 	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -106,6 +108,42 @@ var queryTests = []queryTest{
 			args: []interface{}{0},
 		},
 	},
+	queryTest{
+		ExpectedResult: queryResult{
+			postgres: "SELECT '?', \"a?\", `b?` FROM t WHERE c = $1 -- d?\n AND e /* f? /* g? */ h? */ = $2;",
+			mysql:    "SELECT '?', \"a?\", `b?` FROM t WHERE c = ? -- d?\n AND e /* f? /* g? */ h? */ = ?;",
+			err:      nil,
+		},
+		Query: &Query{
+			sql:  "SELECT '?', \"a?\", `b?` FROM t WHERE c = ? -- d?\n AND e /* f? /* g? */ h? */ = ?",
+			args: []interface{}{0, 1},
+		},
+	},
+	queryTest{
+		ExpectedResult: queryResult{
+			postgres: "SELECT 'it''s?', E'\\'?', $$a?$$, $tag$b$$?$tag$ FROM t WHERE c = $1;",
+			mysql:    "SELECT 'it''s?', E'\\'?', $$a?$$, $tag$b$$?$tag$ FROM t WHERE c = ?;",
+			err:      nil,
+		},
+		Query: &Query{
+			sql:  "SELECT 'it''s?', E'\\'?', $$a?$$, $tag$b$$?$tag$ FROM t WHERE c = ?",
+			args: []interface{}{0},
+		},
+	},
+	queryTest{
+		ExpectedResult: queryResult{
+			postgres: "",
+			mysql:    "",
+			err: ErrWrongNumberArgs{
+				NumExpected: 0,
+				NumFound:    1,
+			},
+		},
+		Query: &Query{
+			sql:  "SELECT * FROM t WHERE a = '?' AND b ?? 'c'",
+			args: []interface{}{0},
+		},
+	},
 	queryTest{
 		ExpectedResult: queryResult{
 			err: ErrNeedsFlush,
@@ -169,7 +207,7 @@ func TestErrWrongNumberArgs(t *testing.T) {
 	t.Parallel()
 	q := New("?")
 	q.args = append(q.args, 1, 2, 3)
-	err := q.checkCounts()
+	err := q.checkCounts(nil)
 	if err == nil {
 		t.Errorf("Expected error.")
 	}
@@ -188,6 +226,26 @@ func TestErrWrongNumberArgs(t *testing.T) {
 	}
 }
 
+func TestBackslashEscapes(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM t WHERE").Expression(`a = 'it\'s ?' AND b = "\"?" AND c = ? AND d = ?`, 1, 2).Flush(" ")
+	query, args, err := q.SQL(MySQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	expected := `SELECT * FROM t WHERE a = 'it\'s ?' AND b = "\"?" AND c = ? AND d = ?;`
+	if query != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, query)
+	}
+	if !reflect.DeepEqual(args, []any{1, 2}) {
+		t.Errorf("Expected args %v, got %v", []any{1, 2}, args)
+	}
+	// without backslash escapes, the first string ends at the second quote
+	if _, _, err := q.SQL(PostgreSQL); err == nil {
+		t.Errorf("Expected an error rendering for PostgreSQL, got nil")
+	}
+}
+
 func TestRepeatedOrder(t *testing.T) {
 	t.Parallel()
 	q := New("SELECT * FROM test_data")
@@ -203,6 +261,88 @@ func TestRepeatedOrder(t *testing.T) {
 	}
 }
 
+func TestRepeatedGroup(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT author_id, COUNT(*) FROM test_data")
+	q.GroupBy("author_id", "title")
+	q.GroupBy("body")
+	q.Having(Expression("COUNT(*) > ?", 1))
+	q.Having(Expression("MIN(created) < ? OR MAX(created) > ?", 2, 3), Expression("SUM(id) = ?", 4))
+	res, err := q.Flush(" ").PostgreSQLString()
+	if err != nil {
+		t.Errorf("Unexpected error: %+v\n", err)
+	}
+	expected := "SELECT author_id, COUNT(*) FROM test_data GROUP BY author_id, title , body HAVING COUNT(*) > $1 AND (MIN(created) < $2 OR MAX(created) > $3) AND SUM(id) = $4;"
+	if res != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, res)
+	}
+}
+
+func TestRepeatedWhere(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	q := New("SELECT * FROM test_data")
+	q.Where(Comparison(p, "Author", "=", 1))
+	q.OrderBy("id").Limit(10)
+	q.Where(Or(Comparison(p, "Title", "=", "a"), Comparison(p, "Title", "=", "b")))
+	res, args, err := q.Flush(" ").SQL(PostgreSQL)
+	if err != nil {
+		t.Errorf("Unexpected error: %+v\n", err)
+	}
+	expected := "SELECT * FROM test_data WHERE author_id = $1 AND (title = $2 OR title = $3) ORDER BY id LIMIT $4;"
+	if res != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, res)
+	}
+	if !reflect.DeepEqual(args, []any{1, "a", "b", int64(10)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+	if !reflect.DeepEqual(q.Args(), args) {
+		t.Errorf("Expected Args to return %v, got %v", args, q.Args())
+	}
+
+	sub := New("SELECT id FROM test_data").Where(Comparison(p, "Body", "=", "x")).Flush(" ")
+	q = New("SELECT * FROM test_data").Where(In(p, "ID", sub)).OrderBy("id").Flush(" ")
+	q.Where(Comparison(p, "Author", "=", 2))
+	res, err = q.Flush(" ").MySQLString()
+	if err != nil {
+		t.Errorf("Unexpected error: %+v\n", err)
+	}
+	expected = "SELECT * FROM test_data WHERE id IN (SELECT id FROM test_data WHERE body = ?) AND author_id = ? ORDER BY id;"
+	if res != expected {
+		t.Errorf("Expected `%s`, got `%s`", expected, res)
+	}
+}
+
+func TestMixedWhere(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	tests := []*Query{
+		New("SELECT * FROM test_data").Where().Comparison(p, "ID", "=", 1).OrderBy("id").Flush(" ").Where(Comparison(p, "Author", "=", 2)).Flush(" "),
+		New("SELECT * FROM test_data").Where(Comparison(p, "Author", "=", 2)).Where().Comparison(p, "ID", "=", 1).Flush(" "),
+	}
+	for pos, q := range tests {
+		if _, _, err := q.SQL(PostgreSQL); err != ErrMixedWhere {
+			t.Errorf("Test %d: expected %v, got %v", pos+1, ErrMixedWhere, err)
+		}
+	}
+}
+
+func TestEmptyWhere(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data").Where(And(), Or()).OrderBy("id").Limit(1).Flush(" ")
+	q.Where(Condition{})
+	res, args, err := q.SQL(MySQL)
+	if err != nil {
+		t.Errorf("Unexpected error: %+v\n", err)
+	}
+	if res != "SELECT * FROM test_data ORDER BY id LIMIT ?;" {
+		t.Errorf("Expected `%s`, got `%s`", "SELECT * FROM test_data ORDER BY id LIMIT ?;", res)
+	}
+	if !reflect.DeepEqual(args, []any{int64(1)}) {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
 func TestOffset(t *testing.T) {
 	t.Parallel()
 	q := New("SELECT * FROM test_data")
@@ -226,6 +366,113 @@ func TestOffset(t *testing.T) {
 	}
 }
 
+func TestStringSkipsLiterals(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT 'a?' FROM t WHERE b ?? c AND d = ?")
+	q.args = append(q.args, 1)
+	if q.String() != "SELECT 'a?' FROM t WHERE b ? c AND d = 1" {
+		t.Errorf("Expected `%s`, got `%s`", "SELECT 'a?' FROM t WHERE b ? c AND d = 1", q.String())
+	}
+}
+
+func TestNamedParameters(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data WHERE")
+	q.Expression("(author_id = :author OR editor_id = :author) AND created > :since AND title != ?", NamedArgs{"author": 1, "since": 2}, "draft")
+	q.Expression("AND id::text = :id", NamedArgs{"id": 3})
+	q.Flush(" ")
+
+	tests := map[Dialect]struct {
+		sql  string
+		args []any
+	}{
+		MySQL: {
+			sql:  "SELECT * FROM test_data WHERE (author_id = ? OR editor_id = ?) AND created > ? AND title != ? AND id::text = ?;",
+			args: []any{1, 1, 2, "draft", 3},
+		},
+		PostgreSQL: {
+			sql:  "SELECT * FROM test_data WHERE (author_id = $1 OR editor_id = $1) AND created > $2 AND title != $3 AND id::text = $4;",
+			args: []any{1, 2, "draft", 3},
+		},
+		Oracle: {
+			sql:  "SELECT * FROM test_data WHERE (author_id = :1 OR editor_id = :2) AND created > :3 AND title != :4 AND id::text = :5",
+			args: []any{1, 1, 2, "draft", 3},
+		},
+	}
+	for dialect, expected := range tests {
+		res, args, err := q.SQL(dialect)
+		if err != nil {
+			t.Errorf("Unexpected error: %+v\n", err)
+		}
+		if res != expected.sql {
+			t.Errorf("Expected `%s`, got `%s`", expected.sql, res)
+		}
+		if !reflect.DeepEqual(args, expected.args) {
+			t.Errorf("Expected args %v, got %v", expected.args, args)
+		}
+	}
+	if q.String() != "SELECT * FROM test_data WHERE (author_id = 1 OR editor_id = 1) AND created > 2 AND title != draft AND id::text = 3" {
+		t.Errorf("Unexpected String result: `%s`", q.String())
+	}
+}
+
+func TestNamedParameterErrors(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data WHERE").Expression("id = :id AND ':missing' = ':missing'", NamedArgs{"id": 1, "unused": 2}).Flush(" ")
+	_, _, err := q.SQL(PostgreSQL)
+	if err != (ErrUnusedNamedArg{Name: "unused"}) {
+		t.Errorf("Expected %v, got %v", ErrUnusedNamedArg{Name: "unused"}, err)
+	}
+	q = New("SELECT * FROM test_data WHERE").Expression("id = :id AND author_id = :author", NamedArgs{"id": 1}).Flush(" ")
+	_, _, err = q.SQL(PostgreSQL)
+	if err != (ErrMissingNamedArg{Name: "author"}) {
+		t.Errorf("Expected %v, got %v", ErrMissingNamedArg{Name: "author"}, err)
+	}
+}
+
+func TestValuesArentNamedArgs(t *testing.T) {
+	t.Parallel()
+	doc := map[string]any{"title": "x"}
+	q := New("UPDATE test_data SET").Expression("data = ?, title = :title", doc).Flush(" ")
+	query, args, err := q.SQL(PostgreSQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if query != "UPDATE test_data SET data = $1, title = :title;" {
+		t.Errorf("Expected `%s`, got `%s`", "UPDATE test_data SET data = $1, title = :title;", query)
+	}
+	if !reflect.DeepEqual(args, []any{doc}) {
+		t.Errorf("Expected args %v, got %v", []any{doc}, args)
+	}
+
+	q = New("SELECT * FROM test_data WHERE").Expression("id = @id AND title = ?", sql.Named("id", 1), "a").Flush(" ")
+	query, args, err = q.SQL(SQLServer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if query != "SELECT * FROM test_data WHERE id = @id AND title = @p1;" {
+		t.Errorf("Expected `%s`, got `%s`", "SELECT * FROM test_data WHERE id = @id AND title = @p1;", query)
+	}
+	if !reflect.DeepEqual(args, []any{"a", sql.Named("id", 1)}) {
+		t.Errorf("Expected args %v, got %v", []any{"a", sql.Named("id", 1)}, args)
+	}
+}
+
+func TestSQLNamedPlaceholders(t *testing.T) {
+	t.Parallel()
+	q := New("SELECT * FROM test_data WHERE").Expression("author_id = :u OR editor_id = :u AND id = @id", sql.Named("u", 1), sql.Named("id", 2)).Flush(" ")
+	query, args, err := q.SQL(PostgreSQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if query != "SELECT * FROM test_data WHERE author_id = $1 OR editor_id = $1 AND id = @id;" {
+		t.Errorf("Expected `%s`, got `%s`", "SELECT * FROM test_data WHERE author_id = $1 OR editor_id = $1 AND id = @id;", query)
+	}
+	if !reflect.DeepEqual(args, []any{1, sql.Named("id", 2)}) {
+		t.Errorf("Expected args %v, got %v", []any{1, sql.Named("id", 2)}, args)
+	}
+}
+
 func BenchmarkMySQLString(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
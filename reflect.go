@@ -6,12 +6,8 @@ import (
 	"sort"
 	"strings"
 	"sync"
-	"unicode"
-	"unicode/utf8"
-)
 
-const (
-	tagName = "sql_column" // The tag that will be read
+	"darlinggo.co/pan/internal/fields"
 )
 
 var (
@@ -19,57 +15,6 @@ var (
 	structReadMutex sync.RWMutex
 )
 
-func validTag(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, c := range s {
-		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != rune([]byte("_")[0]) && c != rune([]byte(".")[0]) && c != rune([]byte("-")[0]) {
-			return false
-		}
-	}
-	return true
-}
-
-func toSnake(s string) string {
-	if s == "" {
-		return ""
-	}
-	snake := ""
-	prevWasLower := false
-	buf := make([]byte, 4)
-	for _, c := range s {
-		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
-			continue
-		}
-		if unicode.IsLower(c) {
-			prevWasLower = true
-		} else if unicode.IsUpper(c) {
-			c = unicode.ToLower(c)
-			if prevWasLower {
-				snake += "_"
-			}
-			prevWasLower = false
-		}
-
-		n := utf8.EncodeRune(buf, c)
-		snake += string(buf[0:n])
-	}
-	return snake
-}
-
-func getFieldColumn(f reflect.StructField) string {
-	// Get the SQL column name, from the tag or infer it
-	field := f.Tag.Get(tagName)
-	if field == "-" {
-		return ""
-	}
-	if field == "" || !validTag(field) {
-		field = toSnake(f.Name)
-	}
-	return field
-}
-
 func hasFlags(list []Flag, passed ...Flag) bool {
 	for _, candidate := range passed {
 		var found bool
@@ -86,20 +31,27 @@ func hasFlags(list []Flag, passed ...Flag) bool {
 	return true
 }
 
+// quoteName quotes `name` using the quoting style in `flags`, if there is one.
+func quoteName(name string, flags ...Flag) string {
+	switch {
+	case hasFlags(flags, FlagTicked):
+		return "`" + name + "`"
+	case hasFlags(flags, FlagDoubleQuoted):
+		return `"` + name + `"`
+	case hasFlags(flags, FlagBracketed):
+		return "[" + name + "]"
+	case hasFlags(flags, FlagQuoted):
+		return directive(directiveIdent, name)
+	}
+	return name
+}
+
 func decorateColumns(columns []string, table string, flags ...Flag) []string {
 	results := make([]string, 0, len(columns))
 	for _, name := range columns {
-		if hasFlags(flags, FlagTicked) {
-			name = "`" + name + "`"
-		} else if hasFlags(flags, FlagDoubleQuoted) {
-			name = `"` + name + `"`
-		}
-		if hasFlags(flags, FlagFull, FlagTicked) {
-			name = "`" + table + "`." + name
-		} else if hasFlags(flags, FlagFull, FlagDoubleQuoted) {
-			name = `"` + table + `".` + name
-		} else if hasFlags(flags, FlagFull) {
-			name = table + "." + name
+		name = quoteName(name, flags...)
+		if hasFlags(flags, FlagFull) {
+			name = quoteName(table, flags...) + "." + name
 		}
 		results = append(results, name)
 	}
@@ -108,11 +60,21 @@ func decorateColumns(columns []string, table string, flags ...Flag) []string {
 
 // if needsValues is false, we'll attempt to use the cache and `values` will be nil
 func readStruct(s SQLTableNamer, needsValues bool, flags ...Flag) (columns []string, values []interface{}) {
+	s, table := unalias(s)
+	if table != s.GetSQLTableName() {
+		flags = append([]Flag{FlagFull}, flags...)
+	}
+	if lister, ok := s.(ColumnLister); ok {
+		if needsValues {
+			values = lister.SQLColumnValues()
+		}
+		return decorateColumns(lister.SQLColumns(), table, flags...), values
+	}
 	typ := fmt.Sprintf("%T", s)
 	structReadMutex.RLock()
 	if cached, ok := structReadCache[typ]; !needsValues && ok {
 		structReadMutex.RUnlock()
-		return decorateColumns(cached, s.GetSQLTableName(), flags...), nil
+		return decorateColumns(cached, table, flags...), nil
 	}
 	structReadMutex.RUnlock()
 	v := reflect.ValueOf(s)
@@ -131,7 +93,7 @@ func readStruct(s SQLTableNamer, needsValues bool, flags ...Flag) (columns []str
 			// skip unexported fields
 			continue
 		}
-		field := getFieldColumn(t.Field(i))
+		field := fields.Column(t.Field(i))
 		if field == "" {
 			continue
 		}
@@ -147,7 +109,7 @@ func readStruct(s SQLTableNamer, needsValues bool, flags ...Flag) (columns []str
 	structReadMutex.Lock()
 	structReadCache[typ] = columns
 	structReadMutex.Unlock()
-	return decorateColumns(columns, s.GetSQLTableName(), flags...), values
+	return decorateColumns(columns, table, flags...), values
 }
 
 // Columns returns a ColumnList containing the names of the columns
@@ -161,6 +123,34 @@ func Columns(s SQLTableNamer, flags ...Flag) ColumnList {
 // `property` must be the exact name of a property on `s`, or Column will
 // panic.
 func Column(s SQLTableNamer, property string, flags ...Flag) string {
+	return column(s, property, flags...)
+}
+
+// Col returns the name of the column that `field` maps to for `s`. `s` must
+// be a pointer to a struct, and `field` must be a pointer to one of its
+// exported fields, like `pan.Col(&p, &p.Title)`, or Col will panic. Unlike
+// Column, Col doesn't refer to the field by name, so renaming or removing it
+// is caught by the compiler.
+func Col(s SQLTableNamer, field any, flags ...Flag) string {
+	return column(s, field, flags...)
+}
+
+// column returns the name of the column that `property` maps to for `s`.
+// `property` is either the name of a property on `s`, or a pointer to one of
+// the fields of the struct `s` points to. If it’s neither, column will panic.
+func column(s SQLTableNamer, property any, flags ...Flag) string {
+	s, table := unalias(s)
+	if table != s.GetSQLTableName() {
+		flags = append([]Flag{FlagFull}, flags...)
+	}
+	name, byName := property.(string)
+	if !byName {
+		field, ok := fieldByPointer(s, property)
+		if !ok {
+			panic(fmt.Sprintf("Field not found in type %T: %T isn't a pointer to one of its fields", s, property))
+		}
+		return decorateColumns([]string{fields.Column(field)}, table, flags...)[0]
+	}
 	t := reflect.TypeOf(s)
 	k := t.Kind()
 	for k == reflect.Interface || k == reflect.Ptr {
@@ -170,14 +160,84 @@ func Column(s SQLTableNamer, property string, flags ...Flag) string {
 	if k != reflect.Struct {
 		return ""
 	}
-	field, ok := t.FieldByName(property)
+	field, ok := t.FieldByName(name)
 	if !ok {
-		panic("Field not found in type: " + property)
+		panic("Field not found in type: " + name)
 	}
-	columns := decorateColumns([]string{getFieldColumn(field)}, s.GetSQLTableName(), flags...)
+	columns := decorateColumns([]string{fields.Column(field)}, table, flags...)
 	return columns[0]
 }
 
+// fieldByPointer returns the exported field of the struct `s` points to that
+// `ptr` points to. Fields are matched by their address and type, so no names
+// are looked up.
+func fieldByPointer(s SQLTableNamer, ptr any) (reflect.StructField, bool) {
+	v := reflect.ValueOf(s)
+	p := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		return reflect.StructField{}, false
+	}
+	base, addr := v.Pointer(), p.Pointer()
+	t := v.Elem().Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// skip unexported fields
+			continue
+		}
+		if base+field.Offset == addr && field.Type == p.Type().Elem() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// primaryKeys returns the names of the properties on `s` that are tagged as
+// being part of its primary key.
+func primaryKeys(s SQLTableNamer) []string {
+	s, _ = unalias(s)
+	t := reflect.TypeOf(s)
+	for t != nil && (t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			// skip unexported fields
+			continue
+		}
+		if fields.Column(t.Field(i)) == "" || !fields.IsPrimaryKey(t.Field(i)) {
+			continue
+		}
+		keys = append(keys, t.Field(i).Name)
+	}
+	return keys
+}
+
+// propertyValue returns the value of `property` on `s`. `property` must be
+// the exact name of a property on `s`, or propertyValue will panic.
+func propertyValue(s SQLTableNamer, property string) interface{} {
+	s, _ = unalias(s)
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	field := v.FieldByName(property)
+	if !field.IsValid() {
+		panic("Field not found in type: " + property)
+	}
+	return field.Interface()
+}
+
 // ColumnValues returns the values in `s` for each column in `s`, in the
 // same order `Columns` returns the names.
 func ColumnValues(s SQLTableNamer) []interface{} {
@@ -192,12 +252,82 @@ type SQLTableNamer interface {
 	GetSQLTableName() string
 }
 
+// ColumnLister is implemented by SQLTableNamers that can list their own columns and
+// the values of those columns without using reflection, usually using code generated by
+// the pan-gen command. Columns, ColumnValues, and the functions built on them use it when
+// it’s available.
+type ColumnLister interface {
+	SQLTableNamer
+
+	// SQLColumns returns the names of the columns, undecorated, in the same order
+	// SQLColumnValues returns the values.
+	SQLColumns() []string
+
+	// SQLColumnValues returns the values of the columns.
+	SQLColumnValues() []any
+}
+
+// ScanTargeter is implemented by types that can find the property a column is read into
+// without using reflection, usually using code generated by the pan-gen command. Unmarshal
+// uses it when it’s available.
+type ScanTargeter interface {
+	// SQLScanTarget returns a pointer to the property that `column` is read into,
+	// or nil if no property maps to `column`.
+	SQLScanTarget(column string) any
+}
+
 // Table is a convenient shorthand wrapper for the GetSQLTableName method
 // on `t`.
 func Table(t SQLTableNamer) string {
 	return t.GetSQLTableName()
 }
 
+type aliasedTable struct {
+	SQLTableNamer
+	alias string
+}
+
+// Aliased returns an SQLTableNamer that refers to the table of `obj` by `alias`. Columns,
+// Column, and the functions built on them always return its columns in their alias.column
+// format, as if FlagFull had been passed, and Select, Join, and TableAs write the table
+// as "table AS alias". Aliases make it possible to join a table to itself.
+func Aliased(obj SQLTableNamer, alias string) SQLTableNamer {
+	obj, _ = unalias(obj)
+	return aliasedTable{SQLTableNamer: obj, alias: alias}
+}
+
+// unalias returns the SQLTableNamer that `s` wraps, if it was returned by
+// Aliased, and the name its table should be referred to by.
+func unalias(s SQLTableNamer) (SQLTableNamer, string) {
+	if a, ok := s.(aliasedTable); ok {
+		return a.SQLTableNamer, a.alias
+	}
+	return s, s.GetSQLTableName()
+}
+
+// TableAs returns the name of the table of `t`, quoted using `flags`, followed by its
+// alias if `t` was returned by Aliased, like "table AS alias". It’s meant to be used in
+// FROM and JOIN clauses.
+func TableAs(t SQLTableNamer, flags ...Flag) string {
+	t, name := unalias(t)
+	table := quoteName(Table(t), flags...)
+	if name == Table(t) {
+		return table
+	}
+	return table + " " + directive(directiveAlias) + " " + quoteName(name, flags...)
+}
+
+// instance returns a usable value of `Type`. If `Type` is a pointer, the
+// pointer will point to the zero value of the type it points to, instead of
+// being nil.
+func instance[Type SQLTableNamer]() Type {
+	var t Type
+	if typ := reflect.TypeOf(t); typ != nil && typ.Kind() == reflect.Ptr {
+		return reflect.New(typ.Elem()).Interface().(Type)
+	}
+	return t
+}
+
 // Placeholders returns a formatted string containing `num` placeholders.
 // The placeholders will be comma-separated.
 func Placeholders(num int) string {
@@ -257,8 +387,30 @@ func getColumnAddrs(s Scannable, in []pointer) ([]interface{}, error) {
 // error if it is unable to. If there are more values than `d` has properties
 // associated with columns, `additional` can be supplied to catch the extra values.
 // The variables in `additional` must be a compatible type with and be in the same
-// order as the columns of `s`.
+// order as the columns of `s`. If `dst` fills the ScanTargeter interface, it’s used to
+// find the properties to read each column into, instead of reflection. If `s` has more
+// than one column with the same name, only the first is read into a property.
 func Unmarshal(s Scannable, dst interface{}, additional ...interface{}) error {
+	if targeter, ok := dst.(ScanTargeter); ok {
+		columns, err := s.Columns()
+		if err != nil {
+			return err
+		}
+		// like the reflection path below, only the first column with a
+		// name is read into its property; duplicates are skipped
+		seen := map[string]bool{}
+		addrs := make([]interface{}, 0, len(columns)+len(additional))
+		for _, column := range columns {
+			if seen[column] {
+				continue
+			}
+			seen[column] = true
+			if target := targeter.SQLScanTarget(column); target != nil {
+				addrs = append(addrs, target)
+			}
+		}
+		return s.Scan(append(addrs, additional...)...)
+	}
 	t := reflect.TypeOf(dst)
 	v := reflect.ValueOf(dst)
 	k := t.Kind()
@@ -276,7 +428,7 @@ func Unmarshal(s Scannable, dst interface{}, additional ...interface{}) error {
 			// skip unexported fields
 			continue
 		}
-		field := getFieldColumn(t.Field(i))
+		field := fields.Column(t.Field(i))
 		if field == "" {
 			continue
 		}
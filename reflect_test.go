@@ -3,6 +3,7 @@ package pan
 import (
 	"database/sql"
 	"os"
+	"reflect"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -57,47 +58,6 @@ func TestReflectedProperties(t *testing.T) {
 	}
 }
 
-var tags = map[string]bool{
-	"":          false,
-	"my_data":   true,
-	"my_data_☃": false,
-	"my,data":   false,
-}
-
-func TestValidTag(t *testing.T) {
-	t.Parallel()
-	for input, validity := range tags {
-		if validTag(input) != validity {
-			expectedValidity := "valid"
-			actualValidity := "valid"
-			if !validity {
-				actualValidity = "invalid"
-			}
-			if !validity {
-				expectedValidity = "invalid"
-			}
-			t.Errorf("Expected `%s` to be %s, was %s.", input, expectedValidity, actualValidity)
-		}
-	}
-}
-
-var camelToSnake = map[string]string{
-	"":          "",
-	"myColumn":  "my_column",
-	"MyColumn":  "my_column",
-	"Mycolumn":  "mycolumn",
-	"My☃Column": "my_column",
-}
-
-func TestCamelToSnake(t *testing.T) {
-	t.Parallel()
-	for input, expectedOutput := range camelToSnake {
-		if expectedOutput != toSnake(input) {
-			t.Errorf("Expected `%s` to be `%s`, was `%s`", input, expectedOutput, toSnake(input))
-		}
-	}
-}
-
 type invalidSQLFieldReflector string
 
 func (i invalidSQLFieldReflector) GetSQLTableName() string {
@@ -158,6 +118,51 @@ func TestInvalidColumnTypes(t *testing.T) {
 	t.Errorf("Expected a panic, got `%s` instead.", result)
 }
 
+func TestCol(t *testing.T) {
+	t.Parallel()
+	var tt testType
+	if col := Col(&tt, &tt.MyTaggedInt); col != "tagged_int" {
+		t.Errorf("Expected %s, got %s", "tagged_int", col)
+	}
+	if col := Col(&tt, &tt.MyString, FlagFull, FlagTicked); col != "`test_types`.`my_string`" {
+		t.Errorf("Expected %s, got %s", "`test_types`.`my_string`", col)
+	}
+	if col := Col(Aliased(&tt, "t"), &tt.MyString); col != "t.my_string" {
+		t.Errorf("Expected %s, got %s", "t.my_string", col)
+	}
+	p := testPost{Title: "hi"}
+	q := New("SELECT * FROM "+Table(p)).Where().Comparison(&p, &p.Title, "=", p.Title).Flush(" ")
+	if q.String() != "SELECT * FROM test_data WHERE title = hi" {
+		t.Errorf("Unexpected query %s", q.String())
+	}
+}
+
+func TestInvalidColPointers(t *testing.T) {
+	t.Parallel()
+	var tt, other testType
+	var i int
+	tests := []struct {
+		obj   SQLTableNamer
+		field any
+	}{
+		{obj: &tt, field: &other.MyString},
+		{obj: &tt, field: &i},
+		{obj: tt, field: &tt.MyString},
+		{obj: &tt, field: tt.MyString},
+		{obj: &tt, field: (*string)(nil)},
+	}
+	for pos, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Test %d: expected a panic", pos+1)
+				}
+			}()
+			Col(test.obj, test.field)
+		}()
+	}
+}
+
 func TestOmittedColumn(t *testing.T) {
 	t.Parallel()
 	columns := Columns(&testType{})
@@ -217,3 +222,267 @@ func TestUnmarshal(t *testing.T) {
 	}
 	os.Remove("./test.db")
 }
+
+// testGenerated has its columns listed the way pan-gen generates them, rather
+// than by reflection, so it can map its unexported field to a column.
+type testGenerated struct {
+	ID    int
+	label string
+}
+
+func (t testGenerated) GetSQLTableName() string {
+	return "test_generated"
+}
+
+func (t testGenerated) SQLColumns() []string {
+	return []string{"id", "label"}
+}
+
+func (t testGenerated) SQLColumnValues() []any {
+	return []any{t.ID, t.label}
+}
+
+func (t *testGenerated) SQLScanTarget(column string) any {
+	switch column {
+	case "id":
+		return &t.ID
+	case "label":
+		return &t.label
+	}
+	return nil
+}
+
+func TestGeneratedColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_generated (id integer, label varchar);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := testGenerated{ID: 1, label: "one"}
+	if cols := Columns(Aliased(row, "g")).String(); cols != "g.id, g.label" {
+		t.Errorf("Unexpected columns %s", cols)
+	}
+	query, args, err := Insert(row).SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT label, id, 2 FROM test_generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var res testGenerated
+	var extra int
+	for rows.Next() {
+		if err := Unmarshal(rows, &res, &extra); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res != row {
+		t.Errorf("Expected %+v, got %+v", row, res)
+	}
+	if extra != 2 {
+		t.Errorf("Expected the additional value to be %d, was %d", 2, extra)
+	}
+}
+
+func TestUnmarshalDuplicateColumns(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_generated (id integer, label varchar); insert into test_generated values (1, 'one');")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var generated testGenerated
+	var reflected struct {
+		ID    int
+		Label string
+	}
+	for _, dst := range []any{&generated, &reflected} {
+		rows, err := db.Query("SELECT id, label, id + 1 AS id FROM test_generated")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var extra int
+		for rows.Next() {
+			if err := Unmarshal(rows, dst, &extra); err != nil {
+				t.Fatalf("%T: unexpected error: %+v", dst, err)
+			}
+		}
+		rows.Close()
+		if extra != 2 {
+			t.Errorf("%T: expected the additional value to be %d, was %d", dst, 2, extra)
+		}
+	}
+	if generated.ID != 1 || generated.label != "one" {
+		t.Errorf("Expected the first id column to be read, got %+v", generated)
+	}
+	if reflected.ID != 1 || reflected.Label != "one" {
+		t.Errorf("Expected the first id column to be read, got %+v", reflected)
+	}
+}
+
+func TestUpsertSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_tags (post_id integer, tag varchar, weight integer, primary key (post_id, tag));")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, weight := range []int{1, 2} {
+		query, args, err := Upsert(Conflict{}, testTag{PostID: 1, Tag: "go", Weight: weight}).SQL(SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query, args, err := Upsert(Conflict{DoNothing: true}, testTag{PostID: 1, Tag: "go", Weight: 3}).SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+	query, args, err = Select[testTag]().Flush(" ").SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tags []testTag
+	for rows.Next() {
+		var tag testTag
+		if err = Unmarshal(rows, &tag); err != nil {
+			t.Error(err)
+		}
+		tags = append(tags, tag)
+	}
+	if len(tags) != 1 || tags[0].Weight != 2 {
+		t.Errorf("Expected one tag with weight 2, got %+v", tags)
+	}
+}
+
+func TestReturningSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_tags (post_id integer, tag varchar, weight integer);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := testTag{PostID: 1, Tag: "go", Weight: 1}
+	query, args, err := Insert(tag).Returning(tag, "Tag", "Weight").Flush(" ").SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var inserted testTag
+	for rows.Next() {
+		if err = Unmarshal(rows, &inserted); err != nil {
+			t.Error(err)
+		}
+	}
+	if inserted.Tag != "go" || inserted.Weight != 1 || inserted.PostID != 0 {
+		t.Errorf("Expected the returned tag and weight, got %+v", inserted)
+	}
+}
+
+func TestRecursiveCTESQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	nums := New("SELECT").Expression("? UNION ALL SELECT n + 1 FROM nums WHERE n < ?", 1, 10).Flush(" ")
+	q := New("SELECT SUM(n) FROM nums").Where(Expression("n > ?", 5)).WithRecursive("nums", nums, "n")
+	query, args, err := q.SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int
+	if err := db.QueryRow(query, args...).Scan(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 40 {
+		t.Errorf("Expected %d, got %d", 40, sum)
+	}
+}
+
+func TestLikeSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec("create table test_data (id integer primary key, title varchar, author_id integer, body varchar, created timestamp, modified timestamp);")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var posts []testPost
+	for pos, title := range []string{"100% Off", "100 percent off", "a_b", "axb", "!_b"} {
+		posts = append(posts, testPost{ID: pos + 1, Title: title})
+	}
+	query, args, err := Insert(posts...).SQL(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+	p := testPost{}
+	tests := []struct {
+		cond     Condition
+		expected []int
+	}{
+		{Like(p, "Title", "100%", LikePrefix), []int{1}},
+		{ILike(p, "Title", "% OFF", LikeSuffix), []int{1}},
+		{Like(p, "Title", "_b", LikeSuffix), []int{3, 5}},
+		{Like(p, "Title", "!_", LikeContains), []int{5}},
+	}
+	for _, test := range tests {
+		query, args, err := New("SELECT id FROM test_data").Where(test.cond).OrderBy("id").Flush(" ").SQL(SQLite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s with %v: expected %v, got %v", query, args, test.expected, ids)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package pan
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 )
 
 type testPost struct {
-	ID       int
+	ID       int `sql_column:",pk"`
 	Title    string
 	Author   int `sql_column:"author_id"`
 	Body     string
@@ -18,6 +20,16 @@ func (t testPost) GetSQLTableName() string {
 	return "test_data"
 }
 
+type testTag struct {
+	PostID int    `sql_column:"post_id,pk"`
+	Tag    string `sql_column:"tag,pk"`
+	Weight int
+}
+
+func (t testTag) GetSQLTableName() string {
+	return "test_tags"
+}
+
 func init() {
 	p := testPost{123, "my post", 1, "this is a test post", time.Now(), nil}
 	sqlTable[Insert(p)] = queryResult{
@@ -32,6 +44,305 @@ func init() {
 		postgres: "SELECT id, title, author_id, body, created, modified FROM test_data WHERE created > (SELECT created FROM test_data WHERE id = $1) ORDER BY created DESC LIMIT $2;",
 		mysql:    "SELECT id, title, author_id, body, created, modified FROM test_data WHERE created > (SELECT created FROM test_data WHERE id = ?) ORDER BY created DESC LIMIT ?;",
 	}
+	sub := New("SELECT "+Column(p, "Created")+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", 123).Flush(" ")
+	sqlTable[New("SELECT "+Columns(p).String()+" FROM "+Table(p)).Where().Comparison(p, "Author", "=", 1).Expression("AND").Comparison(p, "Created", ">", sub).OrderByDesc(Column(p, "Created")).Limit(19).Flush(" ")] = queryResult{
+		postgres: "SELECT id, title, author_id, body, created, modified FROM test_data WHERE author_id = $1 AND created > (SELECT created FROM test_data WHERE id = $2) ORDER BY created DESC LIMIT $3;",
+		mysql:    "SELECT id, title, author_id, body, created, modified FROM test_data WHERE author_id = ? AND created > (SELECT created FROM test_data WHERE id = ?) ORDER BY created DESC LIMIT ?;",
+	}
+	ids := New("SELECT "+Column(p, "ID")+" FROM "+Table(p)).Where().Expression(Column(p, "Title")+" = :title OR "+Column(p, "Body")+" = :title", NamedArgs{"title": "x"}).Flush(" ")
+	sqlTable[New("DELETE FROM "+Table(p)).Where().In(p, "ID", ids).Flush(" ")] = queryResult{
+		postgres: "DELETE FROM test_data WHERE id IN (SELECT id FROM test_data WHERE title = $1 OR body = $1);",
+		mysql:    "DELETE FROM test_data WHERE id IN (SELECT id FROM test_data WHERE title = ? OR body = ?);",
+	}
+	sqlTable[Select[testPost]().Where().Comparison(p, "ID", "=", p.ID).OrderBy(Column(p, "Created")).Limit(10).Flush(" ")] = queryResult{
+		postgres: "SELECT id, title, author_id, body, created, modified FROM test_data WHERE id = $1 ORDER BY created LIMIT $2;",
+		mysql:    "SELECT id, title, author_id, body, created, modified FROM test_data WHERE id = ? ORDER BY created LIMIT ?;",
+	}
+	sqlTable[Select[*testPost](Distinct(), SelectProperties("Author", "Title"), SelectFlags(FlagFull, FlagQuoted)).Flush(" ")] = queryResult{
+		postgres: `SELECT DISTINCT "test_data"."author_id", "test_data"."title" FROM "test_data";`,
+		mysql:    "SELECT DISTINCT `test_data`.`author_id`, `test_data`.`title` FROM `test_data`;",
+	}
+	sqlTable[Update(p, "Title", "Author").Where().Comparison(p, "ID", "=", p.ID).Flush(" ")] = queryResult{
+		mysql:    "UPDATE test_data SET title = ?, author_id = ? WHERE id = ?;",
+		postgres: "UPDATE test_data SET title = $1, author_id = $2 WHERE id = $3;",
+	}
+	sqlTable[Update(&p).Where().Comparison(p, "ID", "=", p.ID).Flush(" ")] = queryResult{
+		mysql:    "UPDATE test_data SET id = ?, title = ?, author_id = ?, body = ?, created = ?, modified = ? WHERE id = ?;",
+		postgres: "UPDATE test_data SET id = $1, title = $2, author_id = $3, body = $4, created = $5, modified = $6 WHERE id = $7;",
+	}
+	sqlTable[Delete[testPost]().Where().Comparison(p, "Author", "=", p.Author).Flush(" ")] = queryResult{
+		mysql:    "DELETE FROM test_data WHERE author_id = ?;",
+		postgres: "DELETE FROM test_data WHERE author_id = $1;",
+	}
+	sqlTable[DeleteByPK(p)] = queryResult{
+		mysql:    "DELETE FROM test_data WHERE id = ?;",
+		postgres: "DELETE FROM test_data WHERE id = $1;",
+	}
+	sqlTable[DeleteByPK(&testTag{PostID: 1, Tag: "go"})] = queryResult{
+		mysql:    "DELETE FROM test_tags WHERE post_id = ? AND tag = ?;",
+		postgres: "DELETE FROM test_tags WHERE post_id = $1 AND tag = $2;",
+	}
+	tag := testTag{PostID: 1, Tag: "go", Weight: 2}
+	sqlTable[Upsert(Conflict{}, tag)] = queryResult{
+		mysql:    "INSERT INTO test_tags (post_id, tag, weight) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE weight = VALUES(weight);",
+		postgres: "INSERT INTO test_tags (post_id, tag, weight) VALUES ($1, $2, $3) ON CONFLICT (post_id, tag) DO UPDATE SET weight = EXCLUDED.weight;",
+	}
+	sqlTable[Upsert(Conflict{DoNothing: true}, tag, tag)] = queryResult{
+		mysql:    "INSERT IGNORE INTO test_tags (post_id, tag, weight) VALUES (?, ?, ?), (?, ?, ?);",
+		postgres: "INSERT INTO test_tags (post_id, tag, weight) VALUES ($1, $2, $3), ($4, $5, $6) ON CONFLICT (post_id, tag) DO NOTHING;",
+	}
+	sqlTable[Upsert(Conflict{Target: []string{"Title"}}, p)] = queryResult{
+		mysql:    "INSERT INTO test_data (id, title, author_id, body, created, modified) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE author_id = VALUES(author_id), body = VALUES(body), created = VALUES(created), modified = VALUES(modified);",
+		postgres: "INSERT INTO test_data (id, title, author_id, body, created, modified) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (title) DO UPDATE SET author_id = EXCLUDED.author_id, body = EXCLUDED.body, created = EXCLUDED.created, modified = EXCLUDED.modified;",
+	}
+	sqlTable[Upsert(Conflict{Target: []string{"Title"}, Update: []string{"Body", "Modified"}}, p)] = queryResult{
+		mysql:    "INSERT INTO test_data (id, title, author_id, body, created, modified) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE body = VALUES(body), modified = VALUES(modified);",
+		postgres: "INSERT INTO test_data (id, title, author_id, body, created, modified) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (title) DO UPDATE SET body = EXCLUDED.body, modified = EXCLUDED.modified;",
+	}
+	sqlTable[Select[testPost](SelectJoined(tag)).Join(InnerJoin, p, "ID", tag, "PostID").Where().Comparison(tag, "Tag", "=", "go").Flush(" ")] = queryResult{
+		mysql:    "SELECT test_data.id, test_data.title, test_data.author_id, test_data.body, test_data.created, test_data.modified, test_tags.post_id, test_tags.tag, test_tags.weight FROM test_data INNER JOIN test_tags ON test_data.id = test_tags.post_id WHERE tag = ?;",
+		postgres: "SELECT test_data.id, test_data.title, test_data.author_id, test_data.body, test_data.created, test_data.modified, test_tags.post_id, test_tags.tag, test_tags.weight FROM test_data INNER JOIN test_tags ON test_data.id = test_tags.post_id WHERE tag = $1;",
+	}
+	sqlTable[Select[testTag](SelectProperties("Tag"), SelectJoined(), SelectFlags(FlagQuoted)).Join(LeftJoin, tag, "PostID", p, "ID", FlagQuoted).Flush(" ")] = queryResult{
+		mysql:    "SELECT `test_tags`.`tag` FROM `test_tags` LEFT JOIN `test_data` ON `test_tags`.`post_id` = `test_data`.`id`;",
+		postgres: `SELECT "test_tags"."tag" FROM "test_tags" LEFT JOIN "test_data" ON "test_tags"."post_id" = "test_data"."id";`,
+	}
+	sqlTable[Select[testTag](SelectProperties("Tag"), SelectJoined()).Join(InnerJoin, &tag, &tag.PostID, &p, &p.ID).Flush(" ")] = queryResult{
+		mysql:    "SELECT test_tags.tag FROM test_tags INNER JOIN test_data ON test_tags.post_id = test_data.id;",
+		postgres: "SELECT test_tags.tag FROM test_tags INNER JOIN test_data ON test_tags.post_id = test_data.id;",
+	}
+	sqlTable[Select[testPost](SelectAs("p"), SelectProperties("ID"), SelectFlags(FlagFull)).Where().Comparison(Aliased(p, "p"), "Title", "=", "hi").Flush(" ")] = queryResult{
+		mysql:    "SELECT p.id FROM test_data AS p WHERE p.title = ?;",
+		postgres: "SELECT p.id FROM test_data AS p WHERE p.title = $1;",
+	}
+	sqlTable[Select[testPost](SelectAs("p"), SelectProperties("ID"), SelectJoined(), SelectFlags(FlagQuoted)).Join(InnerJoin, Aliased(p, "p"), "Author", Aliased(p, "a"), "ID", FlagQuoted).Flush(" ")] = queryResult{
+		mysql:    "SELECT `p`.`id` FROM `test_data` AS `p` INNER JOIN `test_data` AS `a` ON `p`.`author_id` = `a`.`id`;",
+		postgres: `SELECT "p"."id" FROM "test_data" AS "p" INNER JOIN "test_data" AS "a" ON "p"."author_id" = "a"."id";`,
+	}
+	sqlTable[Select[testTag](SelectProperties("PostID")).Where(Comparison(tag, "Weight", ">", 0)).GroupByProperties(tag, "PostID").Having(Expression("COUNT(*) > ?", 2)).OrderBy("COUNT(*)").Flush(" ")] = queryResult{
+		mysql:    "SELECT post_id FROM test_tags WHERE weight > ? GROUP BY post_id HAVING COUNT(*) > ? ORDER BY COUNT(*);",
+		postgres: "SELECT post_id FROM test_tags WHERE weight > $1 GROUP BY post_id HAVING COUNT(*) > $2 ORDER BY COUNT(*);",
+	}
+	recent := New("SELECT " + Column(p, "ID") + " FROM " + Table(p)).Where(Comparison(p, "Author", "=", 1)).OrderByDesc(Column(p, "Created")).Limit(5).Flush(" ")
+	sqlTable[New("SELECT id FROM recent").Where(Expression("id > ?", 2)).With("recent", recent).Flush(" ")] = queryResult{
+		mysql:    "WITH recent AS (SELECT id FROM test_data WHERE author_id = ? ORDER BY created DESC LIMIT ?) SELECT id FROM recent WHERE id > ?;",
+		postgres: "WITH recent AS (SELECT id FROM test_data WHERE author_id = $1 ORDER BY created DESC LIMIT $2) SELECT id FROM recent WHERE id > $3;",
+	}
+	nums := New("SELECT").Expression("? UNION ALL SELECT n + 1 FROM nums WHERE n < ?", 1, 10).Flush(" ")
+	sqlTable[New("SELECT SUM(n) FROM nums, authors").WithRecursive("nums", nums, "n").With("authors", recent).Flush(" ")] = queryResult{
+		mysql:    "WITH RECURSIVE nums (n) AS (SELECT ? UNION ALL SELECT n + 1 FROM nums WHERE n < ?), authors AS (SELECT id FROM test_data WHERE author_id = ? ORDER BY created DESC LIMIT ?) SELECT SUM(n) FROM nums, authors;",
+		postgres: "WITH RECURSIVE nums (n) AS (SELECT $1 UNION ALL SELECT n + 1 FROM nums WHERE n < $2), authors AS (SELECT id FROM test_data WHERE author_id = $3 ORDER BY created DESC LIMIT $4) SELECT SUM(n) FROM nums, authors;",
+	}
+	byAuthor := Select[testPost](SelectProperties("ID")).Where(Comparison(p, "Author", "=", 1)).Flush(" ")
+	byTitle := Select[testPost](SelectProperties("ID")).Where(Comparison(p, "Title", "=", "a")).Flush(" ")
+	sqlTable[Union(byAuthor, byTitle).OrderBy("id").Limit(10).Flush(" ")] = queryResult{
+		mysql:    "SELECT id FROM test_data WHERE author_id = ? UNION SELECT id FROM test_data WHERE title = ? ORDER BY id LIMIT ?;",
+		postgres: "SELECT id FROM test_data WHERE author_id = $1 UNION SELECT id FROM test_data WHERE title = $2 ORDER BY id LIMIT $3;",
+	}
+	sqlTable[UnionAll(byAuthor, byTitle, byAuthor)] = queryResult{
+		mysql:    "SELECT id FROM test_data WHERE author_id = ? UNION ALL SELECT id FROM test_data WHERE title = ? UNION ALL SELECT id FROM test_data WHERE author_id = ?;",
+		postgres: "SELECT id FROM test_data WHERE author_id = $1 UNION ALL SELECT id FROM test_data WHERE title = $2 UNION ALL SELECT id FROM test_data WHERE author_id = $3;",
+	}
+	sqlTable[Intersect(byAuthor, byTitle)] = queryResult{
+		mysql:    "SELECT id FROM test_data WHERE author_id = ? INTERSECT SELECT id FROM test_data WHERE title = ?;",
+		postgres: "SELECT id FROM test_data WHERE author_id = $1 INTERSECT SELECT id FROM test_data WHERE title = $2;",
+	}
+	sqlTable[New("SELECT * FROM test_data").Where(In(p, "ID", Except(byAuthor, byTitle))).Flush(" ")] = queryResult{
+		mysql:    "SELECT * FROM test_data WHERE id IN (SELECT id FROM test_data WHERE author_id = ? EXCEPT SELECT id FROM test_data WHERE title = ?);",
+		postgres: "SELECT * FROM test_data WHERE id IN (SELECT id FROM test_data WHERE author_id = $1 EXCEPT SELECT id FROM test_data WHERE title = $2);",
+	}
+	sqlTable[New("SELECT * FROM test_data").Where(ILike(p, "Title", "Ab_", LikePrefix), IsNotNull(p, "Body")).Flush(" ")] = queryResult{
+		mysql:    "SELECT * FROM test_data WHERE LOWER(title) LIKE ? ESCAPE '!' AND body IS NOT NULL;",
+		postgres: "SELECT * FROM test_data WHERE title ILIKE $1 ESCAPE '!' AND body IS NOT NULL;",
+	}
+}
+
+func TestAliased(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	a := Aliased(Aliased(p, "x"), "a")
+	if Table(a) != Table(p) {
+		t.Errorf("Expected table %s, got %s", Table(p), Table(a))
+	}
+	if col := Column(a, "ID"); col != "a.id" {
+		t.Errorf("Expected column %s, got %s", "a.id", col)
+	}
+	if cols := Columns(a).String(); cols != "a.id, a.title, a.author_id, a.body, a.created, a.modified" {
+		t.Errorf("Unexpected columns %s", cols)
+	}
+	if cols := Columns(p, FlagFull).String(); cols != "test_data.id, test_data.title, test_data.author_id, test_data.body, test_data.created, test_data.modified" {
+		t.Errorf("Alias leaked into the columns cache: %s", cols)
+	}
+	if table := TableAs(p); table != "test_data" {
+		t.Errorf("Expected table %s, got %s", "test_data", table)
+	}
+}
+
+func TestInsertBatches(t *testing.T) {
+	t.Parallel()
+	tags := make([]testTag, 2500)
+	queries, err := InsertBatches(SQLServer, 0, tags...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v\n", err)
+	}
+	// SQL Server allows 2100 arguments, and each tag has 3 columns, so 700 tags fit in a query
+	if len(queries) != 4 {
+		t.Fatalf("Expected %d queries, got %d", 4, len(queries))
+	}
+	for pos, expected := range []int{2100, 2100, 2100, 1200} {
+		if len(queries[pos].Args()) != expected {
+			t.Errorf("Expected query %d to have %d args, got %d", pos+1, expected, len(queries[pos].Args()))
+		}
+	}
+	queries, err = InsertBatches(reversedDialect{}, 0, tags...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v\n", err)
+	}
+	if len(queries) != 1 {
+		t.Errorf("Expected %d query, got %d", 1, len(queries))
+	}
+	// SQLite assumes 999 arguments, so 333 tags fit in a query, unless told otherwise
+	for maxArgs, expected := range map[int]int{0: 8, 32766: 1, 1500: 5} {
+		queries, err = InsertBatches(SQLite, maxArgs, tags...)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v\n", err)
+		}
+		if len(queries) != expected {
+			t.Errorf("Expected %d queries with %d max args, got %d", expected, maxArgs, len(queries))
+		}
+	}
+	queries, err = InsertBatches(reversedDialect{}, 300, tags...)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v\n", err)
+	}
+	if len(queries) != 25 {
+		t.Errorf("Expected %d queries, got %d", 25, len(queries))
+	}
+	if _, err = InsertBatches[testTag](SQLite, 0); err != ErrNoValues {
+		t.Errorf("Expected %v, got %v", ErrNoValues, err)
+	}
+	if _, _, err = Insert[testTag]().SQL(SQLite); err != ErrNoValues {
+		t.Errorf("Expected %v, got %v", ErrNoValues, err)
+	}
+}
+
+func TestUpsertUnsupported(t *testing.T) {
+	t.Parallel()
+	_, _, err := Upsert(Conflict{}, testTag{}).SQL(SQLServer)
+	if _, ok := err.(ErrUnsupported); !ok {
+		t.Errorf("Expected an ErrUnsupported, got %v", err)
+	}
+}
+
+func TestReturning(t *testing.T) {
+	t.Parallel()
+	p := testPost{ID: 123, Title: "my post"}
+	tag := testTag{PostID: 1, Tag: "go", Weight: 2}
+	tests := []struct {
+		query    *Query
+		expected string
+	}{
+		{
+			query:    Insert(tag).Returning(tag).Flush(" "),
+			expected: "INSERT INTO test_tags (post_id, tag, weight) VALUES ($1, $2, $3) RETURNING post_id, tag, weight;",
+		},
+		{
+			query:    Update(p, "Title").Where().Comparison(p, "ID", "=", p.ID).Returning(p, "ID", "Modified").Flush(" "),
+			expected: "UPDATE test_data SET title = $1 WHERE id = $2 RETURNING id, modified;",
+		},
+		{
+			query:    Delete[testTag]().Where().Comparison(&tag, &tag.PostID, "=", tag.PostID).Returning(&tag, &tag.Tag).Flush(" "),
+			expected: "DELETE FROM test_tags WHERE post_id = $1 RETURNING tag;",
+		},
+	}
+	for _, test := range tests {
+		postgres, err := test.query.PostgreSQLString()
+		if err != nil {
+			t.Errorf("Unexpected error: %+v", err)
+		}
+		if postgres != test.expected {
+			t.Errorf("Expected `%s`, got `%s`", test.expected, postgres)
+		}
+		if _, err := test.query.MySQLString(); !errors.Is(err, ErrUnsupported{}) {
+			t.Errorf("Expected an ErrUnsupported error, got %v", err)
+		}
+	}
+}
+
+func TestUpsertWithoutKey(t *testing.T) {
+	t.Parallel()
+	for _, conflict := range []Conflict{{}, {Update: []string{"ID"}}} {
+		for _, d := range []Dialect{PostgreSQL, SQLite} {
+			if _, _, err := Upsert(conflict, testType2{ID: "a"}).SQL(d); err != ErrNoPrimaryKey {
+				t.Errorf("Expected %v, got %v", ErrNoPrimaryKey, err)
+			}
+		}
+	}
+	// MySQL resolves conflicts on any unique constraint, so it doesn't need a target
+	query, _, err := Upsert(Conflict{}, testType2{ID: "a"}).SQL(MySQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if query != "INSERT INTO more_tests (id) VALUES (?) ON DUPLICATE KEY UPDATE id = VALUES(id);" {
+		t.Errorf("Expected `%s`, got `%s`", "INSERT INTO more_tests (id) VALUES (?) ON DUPLICATE KEY UPDATE id = VALUES(id);", query)
+	}
+	query, _, err = Upsert(Conflict{DoNothing: true}, testType2{ID: "a"}).SQL(PostgreSQL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if query != "INSERT INTO more_tests (id) VALUES ($1) ON CONFLICT DO NOTHING;" {
+		t.Errorf("Expected `%s`, got `%s`", "INSERT INTO more_tests (id) VALUES ($1) ON CONFLICT DO NOTHING;", query)
+	}
+}
+
+func TestDeleteByPKWithoutKey(t *testing.T) {
+	t.Parallel()
+	_, _, err := DeleteByPK(testType2{ID: "a"}).SQL(PostgreSQL)
+	if err != ErrNoPrimaryKey {
+		t.Errorf("Expected %v, got %v", ErrNoPrimaryKey, err)
+	}
+}
+
+func TestUpdateArgs(t *testing.T) {
+	t.Parallel()
+	p := testPost{ID: 123, Title: "my post", Author: 1}
+	_, args, err := Update(p, "Title", "Author").Where().Comparison(p, "ID", "=", p.ID).Flush(" ").SQL(PostgreSQL)
+	if err != nil {
+		t.Errorf("Unexpected error: %+v\n", err)
+	}
+	if !reflect.DeepEqual(args, []any{"my post", 1, 123}) {
+		t.Errorf("Expected args %v, got %v", []any{"my post", 1, 123}, args)
+	}
+}
+
+func TestUnflushedCTE(t *testing.T) {
+	t.Parallel()
+	cte := New("SELECT id FROM test_data").Where().Expression("id > ?", 1)
+	q := New("SELECT * FROM cte").With("cte", cte).Flush(" ")
+	if _, _, err := q.SQL(PostgreSQL); err != ErrNeedsFlush {
+		t.Errorf("Expected %v, got %v", ErrNeedsFlush, err)
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	t.Parallel()
+	if _, _, err := Union().SQL(PostgreSQL); err != ErrNoQueries {
+		t.Errorf("Expected %v, got %v", ErrNoQueries, err)
+	}
+	unflushed := New("SELECT id FROM test_data").Where().Expression("id > ?", 1)
+	if _, _, err := UnionAll(New("SELECT id FROM test_data"), unflushed).SQL(PostgreSQL); err != ErrNeedsFlush {
+		t.Errorf("Expected %v, got %v", ErrNeedsFlush, err)
+	}
+}
+
+func TestUnflushedSubquery(t *testing.T) {
+	t.Parallel()
+	p := testPost{}
+	sub := New("SELECT "+Column(p, "ID")+" FROM "+Table(p)).Where().Comparison(p, "ID", "=", 1)
+	q := New("SELECT * FROM "+Table(p)).Where().In(p, "ID", sub).Flush(" ")
+	if _, _, err := q.SQL(PostgreSQL); err != ErrNeedsFlush {
+		t.Errorf("Expected %v, got %v", ErrNeedsFlush, err)
+	}
 }
 
 var sqlTable = map[*Query]queryResult{